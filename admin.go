@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+const adminUsage = "usage: tubely admin [grant | revoke] <email>"
+
+// runAdmin implements the `admin` subcommand, which is the only way to give
+// a user the admin scope. The change applies from their next login or
+// refresh.
+func runAdmin(dbDriver, dbURL string, args []string) {
+	if len(args) != 2 {
+		log.Fatal(adminUsage)
+	}
+	var isAdmin bool
+	switch args[0] {
+	case "grant":
+		isAdmin = true
+	case "revoke":
+	default:
+		log.Fatal(adminUsage)
+	}
+
+	db, err := database.Open(dbDriver, dbURL)
+	if err != nil {
+		log.Fatalf("Couldn't connect to database: %v", err)
+	}
+	defer db.Close()
+
+	user, err := db.GetUserByEmail(args[1])
+	if err != nil {
+		log.Fatalf("Couldn't get user %s: %v", args[1], err)
+	}
+	err = db.SetUserAdmin(user.ID, isAdmin)
+	if err != nil {
+		log.Fatalf("Couldn't update user %s: %v", args[1], err)
+	}
+
+	if isAdmin {
+		fmt.Printf("%s is now an admin\n", user.Email)
+	} else {
+		fmt.Printf("%s is no longer an admin\n", user.Email)
+	}
+}
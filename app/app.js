@@ -26,11 +26,10 @@ async function createVideoDraft() {
   const description = document.getElementById('video-description').value;
 
   try {
-    const res = await fetch('/api/videos', {
+    const res = await apiFetch('/api/videos', {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
       },
       body: JSON.stringify({ title, description }),
     });
@@ -68,6 +67,7 @@ async function login() {
 
     if (data.token) {
       localStorage.setItem('token', data.token);
+      localStorage.setItem('refreshToken', data.refresh_token);
       document.getElementById('auth-section').style.display = 'none';
       document.getElementById('video-section').style.display = 'block';
       await getVideos();
@@ -79,6 +79,58 @@ async function login() {
   }
 }
 
+// apiFetch calls the API with the stored access token. Access tokens are
+// short-lived, so on a 401 it trades the refresh token for new tokens and
+// tries once more.
+async function apiFetch(url, options = {}) {
+  const send = () =>
+    fetch(url, {
+      ...options,
+      headers: {
+        ...options.headers,
+        Authorization: `Bearer ${localStorage.getItem('token')}`,
+      },
+    });
+
+  const res = await send();
+  if (res.status !== 401 || !(await refreshTokens())) {
+    return res;
+  }
+  return send();
+}
+
+let refreshing = null;
+
+// refreshTokens rotates the refresh token and reports whether it worked.
+// Concurrent callers share one request, since presenting a refresh token
+// twice revokes the whole session.
+function refreshTokens() {
+  if (!refreshing) {
+    refreshing = (async () => {
+      const refreshToken = localStorage.getItem('refreshToken');
+      if (!refreshToken) {
+        return false;
+      }
+      const res = await fetch('/api/refresh', {
+        method: 'POST',
+        headers: {
+          Authorization: `Bearer ${refreshToken}`,
+        },
+      });
+      if (!res.ok) {
+        return false;
+      }
+      const data = await res.json();
+      localStorage.setItem('token', data.token);
+      localStorage.setItem('refreshToken', data.refresh_token);
+      return true;
+    })().finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+}
+
 async function signup() {
   const email = document.getElementById('email').value;
   const password = document.getElementById('password').value;
@@ -102,8 +154,19 @@ async function signup() {
   }
 }
 
-function logout() {
+async function logout() {
+  const refreshToken = localStorage.getItem('refreshToken');
+  if (refreshToken) {
+    // Log out locally even if the server can't be reached.
+    await fetch('/api/revoke', {
+      method: 'POST',
+      headers: {
+        Authorization: `Bearer ${refreshToken}`,
+      },
+    }).catch(() => {});
+  }
   localStorage.removeItem('token');
+  localStorage.removeItem('refreshToken');
   document.getElementById('auth-section').style.display = 'block';
   document.getElementById('video-section').style.display = 'none';
 }
@@ -130,11 +193,8 @@ async function uploadThumbnail(videoID) {
   setUploadButtonState(true, uploadBtnSelector);
 
   try {
-    const res = await fetch(`/api/thumbnail_upload/${videoID}`, {
+    const res = await apiFetch(`/api/thumbnail_upload/${videoID}`, {
       method: 'POST',
-      headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
-      },
       body: formData,
     });
     if (!res.ok) {
@@ -163,11 +223,8 @@ async function uploadVideoFile(videoID) {
   setUploadButtonState(true, uploadBtnSelector);
 
   try {
-    const res = await fetch(`/api/video_upload/${videoID}`, {
+    const res = await apiFetch(`/api/video_upload/${videoID}`, {
       method: 'POST',
-      headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
-      },
       body: formData,
     });
     if (!res.ok) {
@@ -188,18 +245,23 @@ const videoStateHandler = createVideoStateHandler();
 
 async function getVideos() {
   try {
-    const res = await fetch('/api/videos', {
-      method: 'GET',
-      headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
-      },
-    });
-    if (!res.ok) {
-      const data = await res.json();
-      throw new Error(`Failed to get videos. Error: ${data.error}`);
-    }
+    // The list is paged; keep following X-Next-Cursor until the last page.
+    const videos = [];
+    let cursor = '';
+    do {
+      const query = cursor ? `?cursor=${encodeURIComponent(cursor)}` : '';
+      const res = await apiFetch(`/api/videos${query}`, {
+        method: 'GET',
+      });
+      if (!res.ok) {
+        const data = await res.json();
+        throw new Error(`Failed to get videos. Error: ${data.error}`);
+      }
+
+      videos.push(...(await res.json()));
+      cursor = res.headers.get('X-Next-Cursor');
+    } while (cursor);
 
-    const videos = await res.json();
     const videoList = document.getElementById('video-list');
     videoList.innerHTML = '';
     for (const video of videos) {
@@ -231,11 +293,8 @@ function createVideoStateHandler() {
 
 async function getVideo(videoID) {
   try {
-    const res = await fetch(`/api/videos/${videoID}`, {
+    const res = await apiFetch(`/api/videos/${videoID}`, {
       method: 'GET',
-      headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
-      },
     });
     if (!res.ok) {
       throw new Error('Failed to get video.');
@@ -283,11 +342,8 @@ async function deleteVideo() {
   }
 
   try {
-    const res = await fetch(`/api/videos/${currentVideo.id}`, {
+    const res = await apiFetch(`/api/videos/${currentVideo.id}`, {
       method: 'DELETE',
-      headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
-      },
     });
     if (!res.ok) {
       throw new Error('Failed to delete video.');
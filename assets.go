@@ -1,7 +1,16 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 )
 
 func (cfg apiConfig) ensureAssetsDir() error {
@@ -10,3 +19,55 @@ func (cfg apiConfig) ensureAssetsDir() error {
 	}
 	return nil
 }
+
+// imageMediaTypes are the formats accepted for thumbnails and avatars.
+var imageMediaTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+// saveImageAsset writes an uploaded image into the assets directory under a
+// random name and returns that name and the file's size. Nothing is left
+// behind on failure.
+func (cfg apiConfig) saveImageAsset(ctx context.Context, src io.Reader, mediaType string) (string, int64, error) {
+	fileExtension, _ := strings.CutPrefix(mediaType, "image/")
+	rnd32 := make([]byte, 32)
+	_, err := rand.Read(rnd32)
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to generate random bytes: %w", err)
+	}
+
+	fileID := base64.RawURLEncoding.EncodeToString(rnd32)
+	fileName := fmt.Sprintf("%v.%v", fileID, fileExtension)
+	filePath := filepath.Join(cfg.assetsRoot, fileName)
+
+	newFile, err := os.Create(filePath)
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to create file: %w", err)
+	}
+	defer newFile.Close()
+
+	size, err := io.Copy(newFile, src)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return "", 0, fmt.Errorf("unable to copy data to file: %w", err)
+	}
+	return fileName, size, nil
+}
+
+func (cfg apiConfig) assetURL(fileName string) string {
+	return fmt.Sprintf("http://localhost:%v/assets/%v", cfg.port, fileName)
+}
+
+// deleteAsset removes a file from the assets directory. It accepts either a
+// bare file name or a URL returned by assetURL.
+func (cfg apiConfig) deleteAsset(nameOrURL string) {
+	fileName := path.Base(nameOrURL)
+	err := os.Remove(filepath.Join(cfg.assetsRoot, fileName))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Couldn't delete asset %s: %v", fileName, err)
+	}
+}
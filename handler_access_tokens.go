@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+// handlerAccessTokenRevoke puts an access token on the deny-list so it stops
+// working before it expires. The body names the token to revoke; without
+// one, the token the request was made with is revoked.
+func (cfg *apiConfig) handlerAccessTokenRevoke(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	userID := authUserID(r)
+	token := authAccessToken(r)
+	if params.Token != "" {
+		token, err = auth.ValidateJWT(params.Token, cfg.jwtKeys)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't validate token", err)
+			return
+		}
+		if token.UserID != userID {
+			respondWithError(w, http.StatusForbidden, "You can't revoke this token", nil)
+			return
+		}
+	}
+	if token.ID == "" {
+		respondWithError(w, http.StatusBadRequest, "No access token to revoke", nil)
+		return
+	}
+
+	err = cfg.db.DenyAccessToken(database.DenyAccessTokenParams{
+		ID:        token.ID,
+		UserID:    token.UserID,
+		ExpiresAt: token.ExpiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke token", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeDeniedAccessTokens drops deny-list entries for tokens that have
+// expired, since they'd be rejected anyway.
+func (cfg *apiConfig) purgeDeniedAccessTokens(ctx context.Context) {
+	n, err := cfg.db.DeleteExpiredDeniedAccessTokens(time.Now())
+	if err != nil {
+		log.Printf("Couldn't purge the access token deny-list: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Purged %d expired tokens from the access token deny-list", n)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxAPIKeyNameLength = 100
+
+func (cfg *apiConfig) handlerAPIKeysCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	type response struct {
+		database.APIKey
+		// Key is only ever shown here.
+		Key string `json:"key"`
+	}
+
+	if !checkNotAPIKey(w, r) {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, "Name can't be empty", nil)
+		return
+	}
+	if utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Name can't be longer than %d characters", maxAPIKeyNameLength), nil)
+		return
+	}
+	if len(params.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required", nil)
+		return
+	}
+	// A key can't do anything the credentials that created it couldn't.
+	scopes := []string{}
+	for _, scope := range params.Scopes {
+		if !auth.IsValidScope(scope) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown scope %q", scope), nil)
+			return
+		}
+		if !auth.HasScope(authScopes(r), scope) {
+			respondWithError(w, http.StatusForbidden, fmt.Sprintf("You can't grant scope %s", scope), nil)
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	key, err := auth.MakeAPIKey()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create API key", err)
+		return
+	}
+	apiKey, err := cfg.db.CreateAPIKey(database.CreateAPIKeyParams{
+		UserID: authUserID(r),
+		Name:   name,
+		Key:    key,
+		Scopes: scopes,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save API key", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		APIKey: apiKey,
+		Key:    key,
+	})
+}
+
+func (cfg *apiConfig) handlerAPIKeysRetrieve(w http.ResponseWriter, r *http.Request) {
+	keys, err := cfg.db.GetAPIKeys(authUserID(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve API keys", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, keys)
+}
+
+func (cfg *apiConfig) handlerAPIKeyDelete(w http.ResponseWriter, r *http.Request) {
+	if !checkNotAPIKey(w, r) {
+		return
+	}
+
+	keyID, err := uuid.Parse(r.PathValue("keyID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid API key ID", err)
+		return
+	}
+
+	err = cfg.db.RevokeAPIKey(authUserID(r), keyID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find API key", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke API key", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkNotAPIKey stops API keys from creating or revoking keys, so a leaked
+// key can't be used to mint more. It writes the error response itself and
+// reports false if the handler should stop.
+func checkNotAPIKey(w http.ResponseWriter, r *http.Request) bool {
+	if authAPIKeyID(r) != uuid.Nil {
+		respondWithError(w, http.StatusForbidden, "API keys can't be managed with an API key", nil)
+		return false
+	}
+	return true
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
@@ -29,10 +31,14 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user, err := cfg.db.GetUserByEmail(params.Email)
-	if err != nil {
+	if errors.Is(err, database.ErrNotFound) {
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
 
 	err = auth.CheckPasswordHash(params.Password, user.Password)
 	if err != nil {
@@ -40,11 +46,7 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(
-		user.ID,
-		cfg.jwtSecret,
-		time.Hour*24*30,
-	)
+	accessToken, err := cfg.makeAccessToken(user)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
 		return
@@ -59,7 +61,9 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	_, err = cfg.db.CreateRefreshToken(database.CreateRefreshTokenParams{
 		UserID:    user.ID,
 		Token:     refreshToken,
-		ExpiresAt: time.Now().UTC().Add(time.Hour * 24 * 60),
+		ExpiresAt: time.Now().UTC().Add(cfg.refreshTokenTTL),
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
@@ -72,3 +76,13 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		RefreshToken: refreshToken,
 	})
 }
+
+// makeAccessToken issues an access token for a user who logged in with
+// their password or a refresh token. Admins also get the admin scope.
+func (cfg *apiConfig) makeAccessToken(user database.User) (string, error) {
+	scopes := auth.UserScopes
+	if user.IsAdmin {
+		scopes = append(slices.Clip(scopes), auth.ScopeAdmin)
+	}
+	return auth.MakeJWT(user.ID, cfg.jwtKeys, cfg.accessTokenTTL, scopes)
+}
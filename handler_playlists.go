@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+type playlistResponse struct {
+	database.Playlist
+	Entries []database.PlaylistEntry `json:"entries"`
+}
+
+// visiblePlaylistEntries drops the entries the user can't view. Someone
+// else's private video stays hidden even when it's in a playlist the user
+// can see, including one they own.
+func visiblePlaylistEntries(userID uuid.UUID, entries []database.PlaylistEntry) []database.PlaylistEntry {
+	visible := []database.PlaylistEntry{}
+	for _, entry := range entries {
+		if canViewVideo(userID, entry.Video) {
+			visible = append(visible, entry)
+		}
+	}
+	return visible
+}
+
+func (cfg *apiConfig) handlerPlaylistsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Visibility  string `json:"visibility"`
+	}
+
+	userID := authUserID(r)
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	title := strings.TrimSpace(params.Title)
+	if msg := validatePlaylistFields(&title, &params.Description, &params.Visibility); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg, nil)
+		return
+	}
+
+	playlist, err := cfg.db.CreatePlaylist(database.CreatePlaylistParams{
+		Title:       title,
+		Description: params.Description,
+		Visibility:  params.Visibility,
+		UserID:      userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create playlist", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, playlist)
+}
+
+// validatePlaylistFields checks the fields that are set and returns a
+// message for the first invalid one, or "" if they're all fine.
+func validatePlaylistFields(title, description, visibility *string) string {
+	if title != nil {
+		if *title == "" {
+			return "Title can't be empty"
+		}
+		if utf8.RuneCountInString(*title) > maxVideoTitleLength {
+			return fmt.Sprintf("Title can't be longer than %d characters", maxVideoTitleLength)
+		}
+	}
+	if description != nil && utf8.RuneCountInString(*description) > maxVideoDescriptionLength {
+		return fmt.Sprintf("Description can't be longer than %d characters", maxVideoDescriptionLength)
+	}
+	if visibility != nil && *visibility != "" && !database.IsValidVisibility(*visibility) {
+		return "Visibility must be private, unlisted or public"
+	}
+	return ""
+}
+
+func (cfg *apiConfig) handlerPlaylistsRetrieve(w http.ResponseWriter, r *http.Request) {
+	userID := authUserID(r)
+
+	playlists, err := cfg.db.GetPlaylists(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve playlists", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, playlists)
+}
+
+func (cfg *apiConfig) handlerPlaylistGet(w http.ResponseWriter, r *http.Request) {
+	playlistID, err := uuid.Parse(r.PathValue("playlistID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid playlist ID", err)
+		return
+	}
+
+	userID := authUserID(r)
+
+	playlist, err := cfg.db.GetPlaylist(playlistID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get playlist", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get playlist", err)
+		return
+	}
+	// Playlists follow the same visibility rules as videos.
+	if playlist.Visibility == database.VisibilityPrivate && playlist.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "Couldn't get playlist", nil)
+		return
+	}
+
+	entries, err := cfg.db.GetPlaylistEntries(playlistID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get playlist entries", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, playlistResponse{
+		Playlist: playlist,
+		Entries:  visiblePlaylistEntries(userID, entries),
+	})
+}
+
+func (cfg *apiConfig) handlerPlaylistUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Visibility  *string `json:"visibility"`
+	}
+
+	playlist, ok := cfg.getOwnedPlaylist(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if params.Title != nil {
+		title := strings.TrimSpace(*params.Title)
+		params.Title = &title
+	}
+	if params.Visibility != nil && *params.Visibility == "" {
+		respondWithError(w, http.StatusBadRequest, "Visibility must be private, unlisted or public", nil)
+		return
+	}
+	if msg := validatePlaylistFields(params.Title, params.Description, params.Visibility); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg, nil)
+		return
+	}
+
+	if params.Title != nil {
+		playlist.Title = *params.Title
+	}
+	if params.Description != nil {
+		playlist.Description = *params.Description
+	}
+	if params.Visibility != nil {
+		playlist.Visibility = *params.Visibility
+	}
+
+	playlist, err = cfg.db.UpdatePlaylist(playlist)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update playlist", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, playlist)
+}
+
+func (cfg *apiConfig) handlerPlaylistDelete(w http.ResponseWriter, r *http.Request) {
+	playlist, ok := cfg.getOwnedPlaylist(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.DeletePlaylist(playlist.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete playlist", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerPlaylistEntryAdd(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		VideoID  uuid.UUID `json:"video_id"`
+		Position *int      `json:"position"`
+	}
+
+	playlist, ok := cfg.getOwnedPlaylist(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	video, err := cfg.db.GetVideo(params.VideoID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get video", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if !canViewVideo(playlist.UserID, video) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get video", nil)
+		return
+	}
+
+	// Without a position the video is appended.
+	position := -1
+	if params.Position != nil {
+		if *params.Position < 0 {
+			respondWithError(w, http.StatusBadRequest, "Position can't be negative", nil)
+			return
+		}
+		position = *params.Position
+	}
+
+	err = cfg.db.AddPlaylistEntry(playlist.ID, video.ID, position)
+	if errors.Is(err, database.ErrAlreadyInPlaylist) {
+		respondWithError(w, http.StatusConflict, err.Error(), err)
+		return
+	}
+	if errors.Is(err, database.ErrPlaylistFull) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Playlists can't have more than %d videos", database.MaxPlaylistEntries), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't add video to playlist", err)
+		return
+	}
+
+	cfg.respondWithPlaylistEntries(w, playlist)
+}
+
+func (cfg *apiConfig) handlerPlaylistEntryMove(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Position *int `json:"position"`
+	}
+
+	playlist, ok := cfg.getOwnedPlaylist(w, r)
+	if !ok {
+		return
+	}
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Position == nil || *params.Position < 0 {
+		respondWithError(w, http.StatusBadRequest, "Position must be zero or more", nil)
+		return
+	}
+
+	err = cfg.db.MovePlaylistEntry(playlist.ID, videoID, *params.Position)
+	if errors.Is(err, database.ErrPlaylistEntryNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't move video", err)
+		return
+	}
+
+	cfg.respondWithPlaylistEntries(w, playlist)
+}
+
+func (cfg *apiConfig) handlerPlaylistEntryDelete(w http.ResponseWriter, r *http.Request) {
+	playlist, ok := cfg.getOwnedPlaylist(w, r)
+	if !ok {
+		return
+	}
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return
+	}
+
+	err = cfg.db.RemovePlaylistEntry(playlist.ID, videoID)
+	if errors.Is(err, database.ErrPlaylistEntryNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove video from playlist", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getOwnedPlaylist loads the playlist named in the path and checks that the
+// caller owns it. It writes the error response itself and reports false if
+// the handler should stop.
+func (cfg *apiConfig) getOwnedPlaylist(w http.ResponseWriter, r *http.Request) (database.Playlist, bool) {
+	playlistID, err := uuid.Parse(r.PathValue("playlistID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid playlist ID", err)
+		return database.Playlist{}, false
+	}
+
+	userID := authUserID(r)
+
+	playlist, err := cfg.db.GetPlaylist(playlistID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get playlist", err)
+		return database.Playlist{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get playlist", err)
+		return database.Playlist{}, false
+	}
+	if playlist.Visibility == database.VisibilityPrivate && playlist.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "Couldn't get playlist", nil)
+		return database.Playlist{}, false
+	}
+	if playlist.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can't edit this playlist", nil)
+		return database.Playlist{}, false
+	}
+	return playlist, true
+}
+
+// respondWithPlaylistEntries writes the playlist with its entries as the
+// owner sees them, after a change to the entries.
+func (cfg *apiConfig) respondWithPlaylistEntries(w http.ResponseWriter, playlist database.Playlist) {
+	playlist, err := cfg.db.GetPlaylist(playlist.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get playlist", err)
+		return
+	}
+	entries, err := cfg.db.GetPlaylistEntries(playlist.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get playlist entries", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, playlistResponse{
+		Playlist: playlist,
+		Entries:  visiblePlaylistEntries(playlist.UserID, entries),
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/media"
+	"github.com/google/uuid"
+)
+
+// An entry for someone else's video is hidden from the playlist's owner once
+// that video is made private, whichever handler returns the entries.
+func TestPlaylistHidesEntriesMadePrivate(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(t *testing.T, cfg *apiConfig, playlist database.Playlist, own database.Video) *httptest.ResponseRecorder
+	}{
+		{
+			name: "get",
+			call: func(t *testing.T, cfg *apiConfig, playlist database.Playlist, own database.Video) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, "/api/playlists/"+playlist.ID.String(), nil)
+				req.SetPathValue("playlistID", playlist.ID.String())
+				w := httptest.NewRecorder()
+				cfg.handlerPlaylistGet(w, withAuth(req, playlist.UserID))
+				return w
+			},
+		},
+		{
+			name: "add",
+			call: func(t *testing.T, cfg *apiConfig, playlist database.Playlist, own database.Video) *httptest.ResponseRecorder {
+				extra, err := cfg.db.CreateVideo(database.CreateVideoParams{
+					Title:      "Extra",
+					Visibility: database.VisibilityPrivate,
+					UserID:     playlist.UserID,
+				})
+				if err != nil {
+					t.Fatalf("Couldn't create video: %v", err)
+				}
+				body := `{"video_id": "` + extra.ID.String() + `"}`
+				req := httptest.NewRequest(http.MethodPost, "/api/playlists/"+playlist.ID.String()+"/entries", strings.NewReader(body))
+				req.SetPathValue("playlistID", playlist.ID.String())
+				w := httptest.NewRecorder()
+				cfg.handlerPlaylistEntryAdd(w, withAuth(req, playlist.UserID))
+				return w
+			},
+		},
+		{
+			name: "move",
+			call: func(t *testing.T, cfg *apiConfig, playlist database.Playlist, own database.Video) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPatch, "/api/playlists/"+playlist.ID.String()+"/entries/"+own.ID.String(), strings.NewReader(`{"position": 0}`))
+				req.SetPathValue("playlistID", playlist.ID.String())
+				req.SetPathValue("videoID", own.ID.String())
+				w := httptest.NewRecorder()
+				cfg.handlerPlaylistEntryMove(w, withAuth(req, playlist.UserID))
+				return w
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := newTestConfig(t, media.Fake{})
+			own := createTestVideo(t, cfg)
+			other := createTestVideo(t, cfg)
+			other.Visibility = database.VisibilityPublic
+			other, err := cfg.db.UpdateVideo(other)
+			if err != nil {
+				t.Fatalf("Couldn't update video: %v", err)
+			}
+
+			playlist, err := cfg.db.CreatePlaylist(database.CreatePlaylistParams{Title: "Mix", UserID: own.UserID})
+			if err != nil {
+				t.Fatalf("Couldn't create playlist: %v", err)
+			}
+			for _, id := range []uuid.UUID{other.ID, own.ID} {
+				err = cfg.db.AddPlaylistEntry(playlist.ID, id, -1)
+				if err != nil {
+					t.Fatalf("Couldn't add playlist entry: %v", err)
+				}
+			}
+			other.Visibility = database.VisibilityPrivate
+			_, err = cfg.db.UpdateVideo(other)
+			if err != nil {
+				t.Fatalf("Couldn't update video: %v", err)
+			}
+
+			w := tt.call(t, cfg, playlist, own)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+			var resp playlistResponse
+			err = json.Unmarshal(w.Body.Bytes(), &resp)
+			if err != nil {
+				t.Fatalf("Couldn't decode response: %v", err)
+			}
+			if len(resp.Entries) == 0 {
+				t.Fatal("response has no entries")
+			}
+			for _, entry := range resp.Entries {
+				if entry.Video.ID == other.ID {
+					t.Errorf("response includes another user's private video")
+				}
+			}
+		})
+	}
+}
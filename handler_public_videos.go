@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+// publicUser is the part of a user's profile anyone may see.
+type publicUser struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   *string   `json:"avatar_url"`
+}
+
+func newPublicUser(user database.User) publicUser {
+	return publicUser{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+	}
+}
+
+func (cfg *apiConfig) handlerPublicVideosRetrieve(w http.ResponseWriter, r *http.Request) {
+	cfg.respondWithPublicVideos(w, r, uuid.Nil)
+}
+
+func (cfg *apiConfig) handlerUserGet(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(userID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newPublicUser(*user))
+}
+
+func (cfg *apiConfig) handlerUserVideosRetrieve(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	// An empty page would look like a user with no public videos.
+	_, err = cfg.db.GetUser(userID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	cfg.respondWithPublicVideos(w, r, userID)
+}
+
+// respondWithPublicVideos writes a page of public videos, newest first,
+// optionally limited to a single user's channel.
+func (cfg *apiConfig) respondWithPublicVideos(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	query := r.URL.Query()
+	params := database.ListVideosParams{
+		UserID:     userID,
+		Visibility: database.VisibilityPublic,
+		Sort:       database.VideoSortCreated,
+		Descending: true,
+		Cursor:     query.Get("cursor"),
+		Tag:        query.Get("tag"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > database.MaxVideoPageSize {
+			msg := fmt.Sprintf("limit must be between 1 and %d", database.MaxVideoPageSize)
+			respondWithError(w, http.StatusBadRequest, msg, err)
+			return
+		}
+		params.Limit = n
+	}
+
+	page, err := cfg.db.ListVideos(params)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+		return
+	}
+	if errors.Is(err, database.ErrInvalidTag) {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve videos", err)
+		return
+	}
+
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	respondWithJSON(w, http.StatusOK, page.Videos)
+}
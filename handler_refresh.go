@@ -1,15 +1,23 @@
 package main
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
 )
 
+// handlerRefresh trades a refresh token for a new access token and a new
+// refresh token that expires when the login's first one does. Each refresh
+// token works once: presenting one that was already rotated means it leaked,
+// so every token descended from the same login is revoked.
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	type response struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	refreshToken, err := auth.GetBearerToken(r.Header)
@@ -18,27 +26,81 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.db.GetUserByRefreshToken(refreshToken)
+	stored, err := cfg.db.GetRefreshToken(refreshToken)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate refresh token", err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get user for refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get refresh token", err)
+		return
+	}
+	if stored.RevokedAt != nil {
+		if stored.Rotated {
+			cfg.revokeRefreshTokenFamily(stored)
+		}
+		respondWithError(w, http.StatusUnauthorized, "Refresh token has been revoked", nil)
+		return
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		respondWithError(w, http.StatusUnauthorized, "Refresh token has expired", nil)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(
-		user.ID,
-		cfg.jwtSecret,
-		time.Hour,
-	)
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
+		return
+	}
+	_, err = cfg.db.RotateRefreshToken(refreshToken, database.CreateRefreshTokenParams{
+		Token:     newRefreshToken,
+		UserID:    stored.UserID,
+		FamilyID:  stored.FamilyID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+	})
+	if errors.Is(err, database.ErrRefreshTokenReused) {
+		// Revoked since it was read: either a concurrent refresh with the
+		// same token, which is reuse, or a logout, which isn't.
+		current, getErr := cfg.db.GetRefreshToken(refreshToken)
+		if getErr == nil && current.Rotated {
+			cfg.revokeRefreshTokenFamily(current)
+		}
+		respondWithError(w, http.StatusUnauthorized, "Refresh token has been revoked", err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rotate refresh token", err)
+		return
+	}
+
+	// Look the user up again so a change to their admin status takes effect
+	// on the next refresh.
+	user, err := cfg.db.GetUser(stored.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+	accessToken, err := cfg.makeAccessToken(*user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, response{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: newRefreshToken,
 	})
 }
 
+func (cfg *apiConfig) revokeRefreshTokenFamily(token database.RefreshToken) {
+	log.Printf("Refresh token reuse detected for user %s, revoking family %s", token.UserID, token.FamilyID)
+	err := cfg.db.RevokeRefreshTokenFamily(token.FamilyID)
+	if err != nil {
+		log.Printf("Couldn't revoke refresh token family %s: %v", token.FamilyID, err)
+	}
+}
+
 func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/media"
+)
+
+func TestHandlerRefreshRevokedToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// revoke revokes the "old" token, which shares a family with "live".
+		revoke         func(db database.Client, old database.RefreshToken) error
+		wantFamilyLive bool
+	}{
+		{
+			name: "rotated",
+			revoke: func(db database.Client, old database.RefreshToken) error {
+				_, err := db.RotateRefreshToken("old", database.CreateRefreshTokenParams{
+					Token:    "rotated",
+					UserID:   old.UserID,
+					FamilyID: old.FamilyID,
+				})
+				return err
+			},
+			wantFamilyLive: false,
+		},
+		{
+			name: "logged out",
+			revoke: func(db database.Client, old database.RefreshToken) error {
+				return db.RevokeRefreshToken("old")
+			},
+			wantFamilyLive: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := newTestConfig(t, media.Fake{})
+			video := createTestVideo(t, cfg)
+			old, err := cfg.db.CreateRefreshToken(database.CreateRefreshTokenParams{
+				Token:     "old",
+				UserID:    video.UserID,
+				ExpiresAt: time.Now().Add(time.Hour),
+			})
+			if err != nil {
+				t.Fatalf("Couldn't create refresh token: %v", err)
+			}
+			_, err = cfg.db.CreateRefreshToken(database.CreateRefreshTokenParams{
+				Token:     "live",
+				UserID:    video.UserID,
+				ExpiresAt: old.ExpiresAt,
+				FamilyID:  old.FamilyID,
+			})
+			if err != nil {
+				t.Fatalf("Couldn't create refresh token: %v", err)
+			}
+			err = tt.revoke(cfg.db, old)
+			if err != nil {
+				t.Fatalf("Couldn't revoke refresh token: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/refresh", nil)
+			req.Header.Set("Authorization", "Bearer old")
+			w := httptest.NewRecorder()
+			cfg.handlerRefresh(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusUnauthorized, w.Body)
+			}
+			live, err := cfg.db.GetRefreshToken("live")
+			if err != nil {
+				t.Fatalf("Couldn't get refresh token: %v", err)
+			}
+			if familyLive := live.RevokedAt == nil; familyLive != tt.wantFamilyLive {
+				t.Errorf("family live = %v, want %v", familyLive, tt.wantFamilyLive)
+			}
+		})
+	}
+}
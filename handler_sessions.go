@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerSessionsRetrieve(w http.ResponseWriter, r *http.Request) {
+	sessions, err := cfg.db.GetSessions(authUserID(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve sessions", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, sessions)
+}
+
+func (cfg *apiConfig) handlerSessionDelete(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID", err)
+		return
+	}
+
+	err = cfg.db.RevokeSession(authUserID(r), sessionID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find session", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerSessionsDelete logs the user out everywhere, including the session
+// making the request. Access tokens already issued stay valid until they
+// expire.
+func (cfg *apiConfig) handlerSessionsDelete(w http.ResponseWriter, r *http.Request) {
+	err := cfg.db.RevokeAllSessions(authUserID(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientIP returns the address of the client the request came from. It
+// ignores X-Forwarded-For, which the client controls.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+func (cfg *apiConfig) handlerVideoTagsRetrieve(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Tags []string `json:"tags"`
+	}
+
+	video, ok := cfg.getViewableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := cfg.db.GetVideoTags(video.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve tags", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{Tags: tags})
+}
+
+func (cfg *apiConfig) handlerVideoTagsSet(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Tags []string `json:"tags"`
+	}
+	type response struct {
+		Tags []string `json:"tags"`
+	}
+
+	video, ok := cfg.getModifiableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	tags, err := cfg.db.SetVideoTags(video, params.Tags)
+	if errors.Is(err, database.ErrInvalidTag) || errors.Is(err, database.ErrTooManyTags) {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't set tags", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{Tags: tags})
+}
+
+func (cfg *apiConfig) handlerVideoTagDelete(w http.ResponseWriter, r *http.Request) {
+	video, ok := cfg.getModifiableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.RemoveVideoTag(video, r.PathValue("tag"))
+	if errors.Is(err, database.ErrInvalidTag) {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove tag", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerTagsRetrieve(w http.ResponseWriter, r *http.Request) {
+	userID := authUserID(r)
+
+	tags, err := cfg.db.GetTags(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve tags", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tags)
+}
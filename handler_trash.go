@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerTrashRetrieve(w http.ResponseWriter, r *http.Request) {
+	userID := authUserID(r)
+
+	videos, err := cfg.db.GetTrashedVideos(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve trash", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, videos)
+}
+
+func (cfg *apiConfig) handlerVideoRestore(w http.ResponseWriter, r *http.Request) {
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid ID", err)
+		return
+	}
+
+	video, err := cfg.db.GetTrashedVideo(videoID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Video isn't in the trash", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if !checkModifiableVideo(w, r, video) {
+		return
+	}
+
+	// A restored video counts towards the quota again, just like a new one.
+	err = cfg.checkVideoQuota(video.UserID)
+	if errors.Is(err, errVideoQuotaExceeded) {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("You can't have more than %d videos", cfg.quota.maxVideos), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check video quota", err)
+		return
+	}
+
+	video, err = cfg.db.RestoreVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore video", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, video)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/media"
+)
+
+func TestHandlerVideoRestoreQuota(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxVideos  int
+		wantStatus int
+	}{
+		{name: "under quota", maxVideos: 2, wantStatus: http.StatusOK},
+		{name: "at quota", maxVideos: 1, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := newTestConfig(t, media.Fake{})
+			cfg.quota.maxVideos = tt.maxVideos
+
+			trashed := createTestVideo(t, cfg)
+			err := cfg.db.TrashVideo(trashed.ID)
+			if err != nil {
+				t.Fatalf("Couldn't trash video: %v", err)
+			}
+			// The replacement takes the slot the trashed video freed up.
+			_, err = cfg.db.CreateVideo(database.CreateVideoParams{
+				Title:      "Boots again",
+				Visibility: database.VisibilityPrivate,
+				UserID:     trashed.UserID,
+			})
+			if err != nil {
+				t.Fatalf("Couldn't create video: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/videos/"+trashed.ID.String()+"/restore", nil)
+			req.SetPathValue("videoID", trashed.ID.String())
+			w := httptest.NewRecorder()
+			cfg.handlerVideoRestore(w, withAuth(req, trashed.UserID))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			_, err = cfg.db.GetTrashedVideo(trashed.ID)
+			stillTrashed := err == nil
+			if stillTrashed != (tt.wantStatus != http.StatusOK) {
+				t.Errorf("still trashed = %v after status %d", stillTrashed, w.Code)
+			}
+		})
+	}
+}
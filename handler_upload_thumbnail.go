@@ -1,47 +1,40 @@
 package main
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"errors"
 	"fmt"
-	"io"
 	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
-
-	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
-	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handlerUploadThumbnail(w http.ResponseWriter, r *http.Request) {
-	videoIDString := r.PathValue("videoID")
-	videoID, err := uuid.Parse(videoIDString)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid ID", err)
+	metaData, ok := cfg.getModifiableVideo(w, r)
+	if !ok {
 		return
 	}
+	userID := authUserID(r)
+
+	fmt.Println("uploading thumbnail for video", metaData.ID, "by user", userID)
 
-	token, err := auth.GetBearerToken(r.Header)
+	plan, err := cfg.uploadPlanFor(userID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to get upload plan", err)
 		return
 	}
-
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+	tooLarge := fmt.Sprintf("thumbnail can't be larger than %d bytes", plan.MaxThumbnailBytes)
+	if r.ContentLength > plan.MaxThumbnailBytes {
+		respondWithError(w, http.StatusRequestEntityTooLarge, tooLarge, nil)
 		return
 	}
-
-	fmt.Println("uploading thumbnail for video", videoID, "by user", userID)
-
-	// TODO: implement the upload here
-	const maxMemory = 10 << 20
-	r.ParseMultipartForm(maxMemory)
+	r.Body = http.MaxBytesReader(w, r.Body, plan.MaxThumbnailBytes)
+	r.ParseMultipartForm(plan.MaxThumbnailBytes)
 
 	file, header, err := r.FormFile("thumbnail")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, tooLarge, err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "unable to parse file", err)
 		return
@@ -53,48 +46,38 @@ func (cfg *apiConfig) handlerUploadThumbnail(w http.ResponseWriter, r *http.Requ
 		respondWithError(w, http.StatusBadRequest, "unable to parse media type", err)
 		return
 	}
-	if mediaType != "image/jpg" && mediaType != "image/png" {
-		respondWithError(w, http.StatusUnsupportedMediaType, "file is not of type .jpg or .png", err)
+	if !imageMediaTypes[mediaType] {
+		respondWithError(w, http.StatusUnsupportedMediaType, "file is not of type .jpg or .png", nil)
 		return
 	}
 
-	fileExtension, _ := strings.CutPrefix(mediaType, "image/")
-	metaData, err := cfg.db.GetVideo(videoID)
+	fileName, size, err := cfg.saveImageAsset(r.Context(), file, mediaType)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "unable to locate video", err)
-		return
-	}
-	rnd32 := make([]byte, 32)
-	_, err = rand.Read(rnd32)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to generate random bytes", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to save thumbnail", err)
 		return
 	}
 
-	fileID := base64.RawURLEncoding.EncodeToString(rnd32)
-	fileName := fmt.Sprintf("%v.%v", fileID, fileExtension)
-	filepath := filepath.Join("./", "assets", fileName)
-
-	newFile, err := os.Create(filepath)
+	err = cfg.checkStorageQuota(userID, metaData.ThumbnailSize, size)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to create file", err)
+		cfg.deleteAsset(fileName)
+		respondWithQuotaError(w, err)
 		return
 	}
 
-	_, err = io.Copy(newFile, file)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to copy data to file", err)
-		return
-	}
-
-	thumbnailURL := fmt.Sprintf("http://localhost:%v/assets/%v", cfg.port, fileName)
+	oldThumbnailURL := metaData.ThumbnailURL
+	thumbnailURL := cfg.assetURL(fileName)
 	metaData.ThumbnailURL = &thumbnailURL
+	metaData.ThumbnailSize = size
 
-	err = cfg.db.UpdateVideo(metaData)
+	metaData, err = cfg.db.UpdateVideo(metaData)
 	if err != nil {
+		cfg.deleteAsset(fileName)
 		respondWithError(w, http.StatusInternalServerError, "unable to update metadata", err)
 		return
 	}
+	if oldThumbnailURL != nil {
+		cfg.deleteAsset(*oldThumbnailURL)
+	}
 
 	respondWithJSON(w, http.StatusOK, metaData)
 }
@@ -1,69 +1,59 @@
 package main
 
 import (
-	"bytes"
-	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math"
 	"mime"
 	"net/http"
 	"os"
-	"os/exec"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
-	"github.com/google/uuid"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/media"
 )
 
-type video struct {
-	Streams []struct {
-		Width  float64 `json:"width"`
-		Height float64 `json:"height"`
-	} `json:"streams"`
-}
-
 func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request) {
-	const maxUploadLimit = 1 << 30
-
-	r.Body = http.MaxBytesReader(w, r.Body, maxUploadLimit)
+	const maxMemory = 32 << 20
 
-	videoIDString := r.PathValue("videoID")
-	videoID, err := uuid.Parse(videoIDString)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid ID", err)
+	metaData, ok := cfg.getModifiableVideo(w, r)
+	if !ok {
 		return
 	}
+	userID := authUserID(r)
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
-		return
+	// Reject uploads that clearly won't fit before reading the body. The
+	// processed file is checked again once its real size is known.
+	if r.ContentLength > 0 {
+		err := cfg.checkStorageQuota(userID, metaData.VideoSize, r.ContentLength)
+		if err != nil {
+			respondWithQuotaError(w, err)
+			return
+		}
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	plan, err := cfg.uploadPlanFor(userID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to get upload plan", err)
 		return
 	}
-	metaData, err := cfg.db.GetVideo(videoID)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "error finding metadata", err)
-		return
-	}
-	if metaData.UserID != userID {
-		respondWithError(w, http.StatusUnauthorized, "access denied", err)
+	if r.ContentLength > plan.MaxVideoBytes {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("video can't be larger than %d bytes", plan.MaxVideoBytes), nil)
 		return
 	}
+	r.Body = http.MaxBytesReader(w, r.Body, plan.MaxVideoBytes)
 
-	fmt.Println("uploading footage for video", videoID, "by user", userID)
-	r.ParseMultipartForm(maxUploadLimit)
+	fmt.Println("uploading footage for video", metaData.ID, "by user", userID)
+	r.ParseMultipartForm(maxMemory)
 
 	file, header, err := r.FormFile("video")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("video can't be larger than %d bytes", plan.MaxVideoBytes), err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to form file", err)
 		return
@@ -79,6 +69,8 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	ctx := r.Context()
+
 	// save the upload to temp file on disk
 	tempFile, err := os.CreateTemp("", "tubely-upload.mp4")
 	if err != nil {
@@ -92,11 +84,31 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusInternalServerError, "unable to copy file", err)
 		return
 	}
-	processedFilePath, err := processVideoForFastStart(tempFile.Name())
+
+	// Check the plan's limits before spending time on processing.
+	probe, err := cfg.media.Probe(ctx, tempFile.Name())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "unable to read video", err)
+		return
+	}
+	err = plan.checkVideo(probe)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	// Processing only moves the moov atom, so the probed dimensions still
+	// hold afterwards.
+	ratio, err := probe.AspectRatio()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "unable to get aspect ratio", err)
+		return
+	}
+	processedFilePath, err := cfg.media.ProcessForFastStart(ctx, tempFile.Name())
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to process video for fast start", err)
 		return
 	}
+	defer os.Remove(processedFilePath)
 	processedFile, err := os.Open(processedFilePath)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to open processed video", err)
@@ -104,17 +116,23 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 	}
 	defer processedFile.Close()
 
-	ratio, err := getVideoAspectRatio(processedFile.Name())
+	processedInfo, err := processedFile.Stat()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to get aspect ratio", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to stat processed video", err)
 		return
 	}
+	err = cfg.checkStorageQuota(userID, metaData.VideoSize, processedInfo.Size())
+	if err != nil {
+		respondWithQuotaError(w, err)
+		return
+	}
+
 	var layout string
 
 	switch ratio {
-	case "16:9":
+	case media.AspectRatioLandscape:
 		layout = "landscape"
-	case "9:16":
+	case media.AspectRatioPortrait:
 		layout = "portrait"
 	default:
 		layout = "other"
@@ -139,63 +157,32 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 		ContentType: &mediaType,
 	}
 
-	_, err = cfg.s3Client.PutObject(context.Background(), &params)
+	_, err = cfg.s3Client.PutObject(ctx, &params)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to put object in s3", err)
 		return
 	}
-	fmt.Printf("video uploaded to s3")
+	fmt.Println("video uploaded to s3")
+	oldVideoURL := metaData.VideoURL
 	videoURL := fmt.Sprintf("%v/%v", cfg.s3CfDistribution, fileName)
 	metaData.VideoURL = &videoURL
-	err = cfg.db.UpdateVideo(metaData)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to update metadata", err)
+	metaData.Orientation = &layout
+	metaData.VideoSize = processedInfo.Size()
+	if err := ctx.Err(); err != nil {
+		cfg.deleteS3Object(ctx, fileName)
+		respondWithError(w, http.StatusRequestTimeout, "upload cancelled", err)
 		return
 	}
-	respondWithJSON(w, http.StatusOK, metaData)
-}
-
-func getVideoAspectRatio(filepath string) (string, error) {
-	cmd := exec.Command("ffprobe", "-v", "error", "-print_format", "json", "-show_streams", filepath)
-	var buffer bytes.Buffer
-	cmd.Stdout = &buffer
-	cmd.Run()
-	var video video
-	err := json.Unmarshal(buffer.Bytes(), &video)
+	metaData, err = cfg.db.UpdateVideo(metaData)
 	if err != nil {
-		return "", fmt.Errorf("unable to marshal data: %v", err)
-	}
-
-	if len(video.Streams) == 0 {
-		return "", fmt.Errorf("no streams available")
-	}
-	v := video.Streams[0]
-	if v.Width == 0 || v.Height == 0 {
-		return "", fmt.Errorf("resolution cannot be 0")
-	}
-
-	ratio := floatToThreeDecimals(v.Width / v.Height)
-
-	switch ratio {
-	case floatToThreeDecimals(16.0 / 9.0):
-		return "16:9", nil
-	case floatToThreeDecimals(9.0 / 16.0):
-		return "9:16", nil
-	default:
-		return "other", nil
+		cfg.deleteS3Object(ctx, fileName)
+		respondWithError(w, http.StatusInternalServerError, "unable to update metadata", err)
+		return
 	}
-}
-
-func floatToThreeDecimals(float float64) float64 {
-	return math.Floor(float*1000) / 1000
-}
-
-func processVideoForFastStart(filePath string) (string, error) {
-	outputFilePath := filePath + ".processing"
-	cmd := exec.Command("ffmpeg", "-i", filePath, "-c", "copy", "-movflags", "faststart", "-f", "mp4", outputFilePath)
-	err := cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("unable to run command")
+	if oldVideoURL != nil {
+		if key, ok := cfg.videoObjectKey(*oldVideoURL); ok {
+			cfg.deleteS3Object(ctx, key)
+		}
 	}
-	return outputFilePath, nil
+	respondWithJSON(w, http.StatusOK, metaData)
 }
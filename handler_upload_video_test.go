@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/media"
+	"github.com/google/uuid"
+)
+
+// fakeS3 records the keys written to and deleted from the bucket.
+type fakeS3 struct {
+	put     []string
+	deleted []string
+	putErr  error
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if f.putErr != nil {
+		return nil, f.putErr
+	}
+	_, err := io.Copy(io.Discard, params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.put = append(f.put, *params.Key)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.deleted = append(f.deleted, *params.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// newTestConfig returns an apiConfig backed by a fresh SQLite database, a
+// fake S3 bucket and a fake media processor.
+func newTestConfig(t *testing.T, processor media.Processor) (*apiConfig, *fakeS3) {
+	t.Helper()
+
+	db, err := database.Open(database.DriverSQLite, filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatalf("Couldn't open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	bucket := &fakeS3{}
+	return &apiConfig{
+		db:               db,
+		platform:         "dev",
+		assetsRoot:       t.TempDir(),
+		s3Bucket:         "tubely-test",
+		s3CfDistribution: "https://cdn.example.com",
+		s3Client:         bucket,
+		media:            processor,
+		accessTokenTTL:   time.Minute,
+		refreshTokenTTL:  time.Hour,
+		plans:            defaultUploadPlans,
+	}, bucket
+}
+
+// createTestVideo creates a user and a video they own.
+func createTestVideo(t *testing.T, cfg *apiConfig) database.Video {
+	t.Helper()
+
+	user, err := cfg.db.CreateUser(database.CreateUserParams{
+		Email:    uuid.NewString() + "@example.com",
+		Password: "hash",
+	})
+	if err != nil {
+		t.Fatalf("Couldn't create user: %v", err)
+	}
+	video, err := cfg.db.CreateVideo(database.CreateVideoParams{
+		Title:      "Boots",
+		Visibility: database.VisibilityPrivate,
+		UserID:     user.ID,
+	})
+	if err != nil {
+		t.Fatalf("Couldn't create video: %v", err)
+	}
+	return video
+}
+
+// withAuth returns r as requireAuth would pass it on for userID after a
+// password login.
+func withAuth(r *http.Request, userID uuid.UUID) *http.Request {
+	info := authInfo{UserID: userID, Scopes: auth.UserScopes}
+	return r.WithContext(context.WithValue(r.Context(), authContextKey, info))
+}
+
+func newUploadVideoRequest(t *testing.T, video database.Video) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="video"; filename="boots.mp4"`)
+	header.Set("Content-Type", "video/mp4")
+	part, err := form.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("not really an mp4"))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/video_upload/"+video.ID.String(), body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.SetPathValue("videoID", video.ID.String())
+	return withAuth(req, video.UserID)
+}
+
+func TestHandlerUploadVideo(t *testing.T) {
+	tests := []struct {
+		name            string
+		processor       media.Fake
+		putErr          error
+		wantStatus      int
+		wantOrientation string
+	}{
+		{
+			name:            "landscape",
+			processor:       media.Fake{Probed: media.ProbeResult{Width: 1920, Height: 1080, Duration: time.Minute}},
+			wantStatus:      http.StatusOK,
+			wantOrientation: "landscape",
+		},
+		{
+			name:            "portrait",
+			processor:       media.Fake{Probed: media.ProbeResult{Width: 720, Height: 1280, Duration: time.Minute}},
+			wantStatus:      http.StatusOK,
+			wantOrientation: "portrait",
+		},
+		{
+			name:            "other",
+			processor:       media.Fake{Probed: media.ProbeResult{Width: 1000, Height: 1000, Duration: time.Minute}},
+			wantStatus:      http.StatusOK,
+			wantOrientation: "other",
+		},
+		{
+			name:       "probe fails",
+			processor:  media.Fake{ProbeErr: errors.New("moov atom not found")},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "no video stream dimensions",
+			processor:  media.Fake{Probed: media.ProbeResult{Duration: time.Minute}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "over plan resolution",
+			processor:  media.Fake{Probed: media.ProbeResult{Width: 3840, Height: 2160, Duration: time.Minute}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "fast start fails",
+			processor: media.Fake{
+				Probed:       media.ProbeResult{Width: 1920, Height: 1080, Duration: time.Minute},
+				FastStartErr: errors.New("ffmpeg exited with status 1"),
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "s3 put fails",
+			processor:  media.Fake{Probed: media.ProbeResult{Width: 1920, Height: 1080, Duration: time.Minute}},
+			putErr:     errors.New("access denied"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, bucket := newTestConfig(t, tt.processor)
+			bucket.putErr = tt.putErr
+			video := createTestVideo(t, cfg)
+
+			w := httptest.NewRecorder()
+			cfg.handlerUploadVideo(w, newUploadVideoRequest(t, video))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			got, err := cfg.db.GetVideo(video.ID)
+			if err != nil {
+				t.Fatalf("Couldn't get video: %v", err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if got.VideoURL != nil {
+					t.Errorf("video URL = %q, want none after a failed upload", *got.VideoURL)
+				}
+				if len(bucket.put) != 0 {
+					t.Errorf("uploaded %v, want nothing", bucket.put)
+				}
+				return
+			}
+
+			if got.Orientation == nil || *got.Orientation != tt.wantOrientation {
+				t.Errorf("orientation = %v, want %q", got.Orientation, tt.wantOrientation)
+			}
+			if len(bucket.put) != 1 || !strings.HasPrefix(bucket.put[0], tt.wantOrientation+"/") {
+				t.Fatalf("uploaded %v, want one object under %s/", bucket.put, tt.wantOrientation)
+			}
+			wantURL := cfg.s3CfDistribution + "/" + bucket.put[0]
+			if got.VideoURL == nil || *got.VideoURL != wantURL {
+				t.Errorf("video URL = %v, want %q", got.VideoURL, wantURL)
+			}
+		})
+	}
+}
+
+func TestHandlerUploadVideoReplacesOldObject(t *testing.T) {
+	cfg, bucket := newTestConfig(t, media.Fake{
+		Probed: media.ProbeResult{Width: 1920, Height: 1080, Duration: time.Minute},
+	})
+	video := createTestVideo(t, cfg)
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		cfg.handlerUploadVideo(w, newUploadVideoRequest(t, video))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+	}
+
+	if len(bucket.put) != 2 {
+		t.Fatalf("uploaded %v, want two objects", bucket.put)
+	}
+	if len(bucket.deleted) != 1 || bucket.deleted[0] != bucket.put[0] {
+		t.Errorf("deleted %v, want the first upload %q", bucket.deleted, bucket.put[0])
+	}
+}
+
+func TestHandlerUploadVideoNotOwner(t *testing.T) {
+	cfg, bucket := newTestConfig(t, media.Fake{
+		Probed: media.ProbeResult{Width: 1920, Height: 1080, Duration: time.Minute},
+	})
+	video := createTestVideo(t, cfg)
+
+	req := withAuth(newUploadVideoRequest(t, video), uuid.New())
+	w := httptest.NewRecorder()
+	cfg.handlerUploadVideo(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body)
+	}
+	if len(bucket.put) != 0 {
+		t.Errorf("uploaded %v, want nothing", bucket.put)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+func (cfg *apiConfig) handlerUsageGet(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		database.StorageUsage
+		MaxBytes     int64      `json:"max_bytes"`
+		MaxVideos    int        `json:"max_videos"`
+		UploadLimits uploadPlan `json:"upload_limits"`
+	}
+
+	userID := authUserID(r)
+
+	usage, err := cfg.db.GetStorageUsage(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get usage", err)
+		return
+	}
+
+	plan, err := cfg.uploadPlanFor(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get upload plan", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		StorageUsage: usage,
+		MaxBytes:     cfg.quota.maxBytes,
+		MaxVideos:    cfg.quota.maxVideos,
+		UploadLimits: plan,
+	})
+}
@@ -2,7 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
@@ -44,3 +49,176 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 
 	respondWithJSON(w, http.StatusCreated, user)
 }
+
+func (cfg *apiConfig) handlerUsersDelete(w http.ResponseWriter, r *http.Request) {
+	userID := authUserID(r)
+
+	// The database cascades the rows, but stored files have to be removed
+	// here while we can still find them.
+	user, err := cfg.db.GetUser(userID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+	videos, err := cfg.db.GetVideos(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve videos", err)
+		return
+	}
+	trashed, err := cfg.db.GetTrashedVideos(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve videos", err)
+		return
+	}
+	videos = append(videos, trashed...)
+
+	err = cfg.db.DeleteUser(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete user", err)
+		return
+	}
+
+	for _, video := range videos {
+		cfg.deleteVideoAssets(r.Context(), video)
+	}
+	if user.AvatarURL != nil {
+		cfg.deleteAsset(*user.AvatarURL)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+const maxDisplayNameLength = 50
+
+func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		DisplayName *string `json:"display_name"`
+	}
+
+	userID := authUserID(r)
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(userID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	profile := database.UpdateUserProfileParams{
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+		AvatarSize:  user.AvatarSize,
+	}
+	if params.DisplayName != nil {
+		displayName := strings.TrimSpace(*params.DisplayName)
+		if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Display name can't be longer than %d characters", maxDisplayNameLength), nil)
+			return
+		}
+		profile.DisplayName = displayName
+	}
+
+	user, err = cfg.db.UpdateUserProfile(userID, profile)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+func (cfg *apiConfig) handlerUploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID := authUserID(r)
+
+	// Avatars are images like thumbnails, so the same plan limit applies.
+	plan, err := cfg.uploadPlanFor(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get upload plan", err)
+		return
+	}
+	tooLarge := fmt.Sprintf("avatar can't be larger than %d bytes", plan.MaxThumbnailBytes)
+	if r.ContentLength > plan.MaxThumbnailBytes {
+		respondWithError(w, http.StatusRequestEntityTooLarge, tooLarge, nil)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, plan.MaxThumbnailBytes)
+	r.ParseMultipartForm(plan.MaxThumbnailBytes)
+
+	file, header, err := r.FormFile("avatar")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, tooLarge, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "unable to parse file", err)
+		return
+	}
+	defer file.Close()
+
+	mediaType, _, err := mime.ParseMediaType(header.Header.Get("Content-Type"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "unable to parse media type", err)
+		return
+	}
+	if !imageMediaTypes[mediaType] {
+		respondWithError(w, http.StatusUnsupportedMediaType, "file is not of type .jpg or .png", nil)
+		return
+	}
+
+	user, err := cfg.db.GetUser(userID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	fileName, size, err := cfg.saveImageAsset(r.Context(), file, mediaType)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to save avatar", err)
+		return
+	}
+
+	err = cfg.checkStorageQuota(userID, user.AvatarSize, size)
+	if err != nil {
+		cfg.deleteAsset(fileName)
+		respondWithQuotaError(w, err)
+		return
+	}
+
+	avatarURL := cfg.assetURL(fileName)
+	oldAvatarURL := user.AvatarURL
+	user, err = cfg.db.UpdateUserProfile(userID, database.UpdateUserProfileParams{
+		DisplayName: user.DisplayName,
+		AvatarURL:   &avatarURL,
+		AvatarSize:  size,
+	})
+	if err != nil {
+		cfg.deleteAsset(fileName)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+	if oldAvatarURL != nil {
+		cfg.deleteAsset(*oldAvatarURL)
+	}
+
+	respondWithJSON(w, http.StatusOK, user)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/media"
+)
+
+func TestHandlerUploadAvatarPlanLimit(t *testing.T) {
+	const limit = 4 << 10
+
+	tests := []struct {
+		name string
+		size int
+		// chunked hides the length so the limit is only hit while reading.
+		chunked    bool
+		wantStatus int
+	}{
+		{name: "within the plan", size: 1 << 10, wantStatus: http.StatusOK},
+		{name: "larger than the plan allows", size: 2 * limit, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "larger than the plan allows, chunked", size: 2 * limit, chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := newTestConfig(t, media.Fake{})
+			cfg.plans = map[string]uploadPlan{
+				defaultPlanName: {MaxVideoBytes: 1 << 20, MaxThumbnailBytes: limit},
+			}
+			user, err := cfg.db.CreateUser(database.CreateUserParams{Email: "boots@example.com", Password: "hash"})
+			if err != nil {
+				t.Fatalf("Couldn't create user: %v", err)
+			}
+
+			body := &bytes.Buffer{}
+			form := multipart.NewWriter(body)
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", `form-data; name="avatar"; filename="boots.png"`)
+			header.Set("Content-Type", "image/png")
+			part, err := form.CreatePart(header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write(bytes.Repeat([]byte{0}, tt.size))
+			form.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/users/me/avatar", body)
+			req.Header.Set("Content-Type", form.FormDataContentType())
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			cfg.handlerUploadAvatar(w, withAuth(req, user.ID))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			got, err := cfg.db.GetUser(user.ID)
+			if err != nil {
+				t.Fatalf("Couldn't get user: %v", err)
+			}
+			if hasAvatar := got.AvatarURL != nil; hasAvatar != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("avatar URL = %v after status %d", got.AvatarURL, w.Code)
+			}
+		})
+	}
+}
@@ -2,11 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
-	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
-	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handlerVideoMetaCreate(w http.ResponseWriter, r *http.Request) {
@@ -14,25 +19,43 @@ func (cfg *apiConfig) handlerVideoMetaCreate(w http.ResponseWriter, r *http.Requ
 		database.CreateVideoParams
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	userID := authUserID(r)
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	params.UserID = userID
+	params.Title, err = validateVideoTitle(params.Title)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	err = validateVideoDescription(params.Description)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if params.Visibility == "" {
+		params.Visibility = database.VisibilityPrivate
+	}
+	if !database.IsValidVisibility(params.Visibility) {
+		respondWithError(w, http.StatusBadRequest, "Visibility must be private, unlisted or public", nil)
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	err = cfg.checkVideoQuota(userID)
+	if errors.Is(err, errVideoQuotaExceeded) {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("You can't have more than %d videos", cfg.quota.maxVideos), err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check video quota", err)
 		return
 	}
-	params.UserID = userID
 
 	video, err := cfg.db.CreateVideo(params.CreateVideoParams)
 	if err != nil {
@@ -44,76 +67,249 @@ func (cfg *apiConfig) handlerVideoMetaCreate(w http.ResponseWriter, r *http.Requ
 }
 
 func (cfg *apiConfig) handlerVideoMetaDelete(w http.ResponseWriter, r *http.Request) {
-	videoIDString := r.PathValue("videoID")
-	videoID, err := uuid.Parse(videoIDString)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid ID", err)
+	video, ok := cfg.getModifiableVideo(w, r)
+	if !ok {
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	// Stored files are kept until the trash is purged so the video can still
+	// be restored.
+	err := cfg.db.TrashVideo(video.ID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete video", err)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+const (
+	maxVideoTitleLength       = 200
+	maxVideoDescriptionLength = 5000
+)
+
+// validateVideoTitle returns the title trimmed of surrounding space, or an
+// error to show the user if it's empty or too long.
+func validateVideoTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", errors.New("title can't be empty")
+	}
+	if utf8.RuneCountInString(title) > maxVideoTitleLength {
+		return "", fmt.Errorf("title can't be longer than %d characters", maxVideoTitleLength)
+	}
+	return title, nil
+}
+
+// validateVideoDescription returns an error to show the user if the
+// description is too long.
+func validateVideoDescription(description string) error {
+	if utf8.RuneCountInString(description) > maxVideoDescriptionLength {
+		return fmt.Errorf("description can't be longer than %d characters", maxVideoDescriptionLength)
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerVideoMetaUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Visibility  *string `json:"visibility"`
+	}
+
+	video, ok := cfg.getModifiableVideo(w, r)
+	if !ok {
 		return
 	}
 
-	video, err := cfg.db.GetVideo(videoID)
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't get video", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
 		return
 	}
-	if video.UserID != userID {
-		respondWithError(w, http.StatusForbidden, "You can't delete this video", err)
+
+	if ifMatch := r.Header.Values("If-Match"); len(ifMatch) > 0 && !etagMatches(ifMatch, videoETag(video)) {
+		respondWithError(w, http.StatusPreconditionFailed, "Video has been modified", nil)
 		return
 	}
 
-	err = cfg.db.DeleteVideo(videoID)
+	if params.Title != nil {
+		video.Title, err = validateVideoTitle(*params.Title)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+	if params.Description != nil {
+		err = validateVideoDescription(*params.Description)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		video.Description = *params.Description
+	}
+	if params.Visibility != nil {
+		if !database.IsValidVisibility(*params.Visibility) {
+			respondWithError(w, http.StatusBadRequest, "Visibility must be private, unlisted or public", nil)
+			return
+		}
+		video.Visibility = *params.Visibility
+	}
+
+	video, err = cfg.db.UpdateVideoIfUnmodified(video)
+	if errors.Is(err, database.ErrConflict) {
+		respondWithError(w, http.StatusPreconditionFailed, "Video has been modified", err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't delete video", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update video", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("ETag", videoETag(video))
+	respondWithJSON(w, http.StatusOK, video)
 }
 
-func (cfg *apiConfig) handlerVideoGet(w http.ResponseWriter, r *http.Request) {
-	videoIDString := r.PathValue("videoID")
-	videoID, err := uuid.Parse(videoIDString)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
-		return
+// videoETag identifies a version of a video's metadata for If-Match checks.
+func videoETag(video database.Video) string {
+	return fmt.Sprintf(`"%d"`, video.UpdatedAt.UnixMicro())
+}
+
+// etagMatches reports whether If-Match header values match etag (RFC 9110
+// section 13.1.1). "*" matches any existing resource, and otherwise any
+// ETag in the comma-separated lists may match. If-Match uses the strong
+// comparison, so weak ETags never match.
+func etagMatches(ifMatch []string, etag string) bool {
+	for _, value := range ifMatch {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	video, err := cfg.db.GetVideo(videoID)
-	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't get video", err)
+func (cfg *apiConfig) handlerVideoGet(w http.ResponseWriter, r *http.Request) {
+	video, ok := cfg.getViewableVideo(w, r)
+	if !ok {
 		return
 	}
+
+	w.Header().Set("ETag", videoETag(video))
 	respondWithJSON(w, http.StatusOK, video)
 }
 
 func (cfg *apiConfig) handlerVideosRetrieve(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+	userID := authUserID(r)
+
+	params, err := parseListVideosParams(r.URL.Query())
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+	params.UserID = userID
+
+	page, err := cfg.db.ListVideos(params)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+		return
+	}
+	if errors.Is(err, database.ErrInvalidTag) {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
-
-	videos, err := cfg.db.GetVideos(userID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve videos", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, videos)
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	respondWithJSON(w, http.StatusOK, page.Videos)
+}
+
+// parseListVideosParams reads the paging, sorting and filtering options for
+// GET /api/videos:
+//
+//	limit, cursor
+//	sort=created|updated|title, order=asc|desc
+//	tag, status=pending|ready, orientation=landscape|portrait|other
+//	has_thumbnail=true|false, created_after, created_before (RFC 3339)
+func parseListVideosParams(query url.Values) (database.ListVideosParams, error) {
+	params := database.ListVideosParams{
+		Cursor: query.Get("cursor"),
+		Sort:   database.VideoSort(query.Get("sort")),
+		Tag:    query.Get("tag"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > database.MaxVideoPageSize {
+			return params, fmt.Errorf("limit must be between 1 and %d", database.MaxVideoPageSize)
+		}
+		params.Limit = n
+	}
+
+	switch params.Sort {
+	case "":
+		params.Sort = database.VideoSortCreated
+	case database.VideoSortCreated, database.VideoSortUpdated, database.VideoSortTitle:
+	default:
+		return params, errors.New("sort must be one of created, updated, title")
+	}
+
+	switch query.Get("order") {
+	case "":
+		// Newest first for dates, alphabetical for titles
+		params.Descending = params.Sort != database.VideoSortTitle
+	case "asc":
+	case "desc":
+		params.Descending = true
+	default:
+		return params, errors.New("order must be asc or desc")
+	}
+
+	params.Status = query.Get("status")
+	switch params.Status {
+	case "", database.VideoStatusPending, database.VideoStatusReady:
+	default:
+		return params, errors.New("status must be pending or ready")
+	}
+
+	params.Orientation = query.Get("orientation")
+	switch params.Orientation {
+	case "", "landscape", "portrait", "other":
+	default:
+		return params, errors.New("orientation must be landscape, portrait or other")
+	}
+
+	if hasThumbnail := query.Get("has_thumbnail"); hasThumbnail != "" {
+		b, err := strconv.ParseBool(hasThumbnail)
+		if err != nil {
+			return params, errors.New("has_thumbnail must be true or false")
+		}
+		params.HasThumbnail = &b
+	}
+
+	if after := query.Get("created_after"); after != "" {
+		t, err := time.Parse(time.RFC3339, after)
+		if err != nil {
+			return params, errors.New("created_after must be an RFC 3339 timestamp")
+		}
+		params.CreatedAfter = &t
+	}
+	if before := query.Get("created_before"); before != "" {
+		t, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			return params, errors.New("created_before must be an RFC 3339 timestamp")
+		}
+		params.CreatedBefore = &t
+	}
+
+	return params, nil
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/media"
+)
+
+func TestHandlerVideoMetaCreate(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantTitle  string
+	}{
+		{
+			name:       "valid",
+			body:       `{"title": "  Boots  ", "description": "A bear"}`,
+			wantStatus: http.StatusCreated,
+			wantTitle:  "Boots",
+		},
+		{
+			name:       "malformed JSON",
+			body:       `{"title": `,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing title",
+			body:       `{"description": "A bear"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "blank title",
+			body:       `{"title": "   "}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "title too long",
+			body:       `{"title": "` + strings.Repeat("a", maxVideoTitleLength+1) + `"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "description too long",
+			body:       `{"title": "Boots", "description": "` + strings.Repeat("a", maxVideoDescriptionLength+1) + `"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid visibility",
+			body:       `{"title": "Boots", "visibility": "secret"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := newTestConfig(t, media.Fake{})
+			user, err := cfg.db.CreateUser(database.CreateUserParams{Email: "boots@example.com", Password: "hash"})
+			if err != nil {
+				t.Fatalf("Couldn't create user: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/videos", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			cfg.handlerVideoMetaCreate(w, withAuth(req, user.ID))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			videos, err := cfg.db.GetVideos(user.ID)
+			if err != nil {
+				t.Fatalf("Couldn't get videos: %v", err)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if len(videos) != 0 {
+					t.Errorf("created %d videos, want none", len(videos))
+				}
+				return
+			}
+
+			var video database.Video
+			err = json.Unmarshal(w.Body.Bytes(), &video)
+			if err != nil {
+				t.Fatalf("Couldn't decode response: %v", err)
+			}
+			if video.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", video.Title, tt.wantTitle)
+			}
+			if video.UserID != user.ID {
+				t.Errorf("user ID = %v, want %v", video.UserID, user.ID)
+			}
+		})
+	}
+}
+
+func TestHandlerVideoMetaUpdateIfMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		// ifMatch builds the If-Match header from the video's current ETag.
+		// An empty result leaves the header out.
+		ifMatch    func(etag string) string
+		wantStatus int
+	}{
+		{
+			name:       "no header",
+			ifMatch:    func(etag string) string { return "" },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "current ETag",
+			ifMatch:    func(etag string) string { return etag },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "stale ETag",
+			ifMatch:    func(etag string) string { return `"1"` },
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "any",
+			ifMatch:    func(etag string) string { return "*" },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list containing the current ETag",
+			ifMatch:    func(etag string) string { return `"1", ` + etag + `,"2"` },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list of stale ETags",
+			ifMatch:    func(etag string) string { return `"1", "2"` },
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "weak current ETag",
+			ifMatch:    func(etag string) string { return "W/" + etag },
+			wantStatus: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := newTestConfig(t, media.Fake{})
+			video := createTestVideo(t, cfg)
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/videos/"+video.ID.String(), strings.NewReader(`{"title": "Renamed"}`))
+			req.SetPathValue("videoID", video.ID.String())
+			if ifMatch := tt.ifMatch(videoETag(video)); ifMatch != "" {
+				req.Header.Set("If-Match", ifMatch)
+			}
+			w := httptest.NewRecorder()
+			cfg.handlerVideoMetaUpdate(w, withAuth(req, video.UserID))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			got, err := cfg.db.GetVideo(video.ID)
+			if err != nil {
+				t.Fatalf("Couldn't get video: %v", err)
+			}
+			if renamed := got.Title == "Renamed"; renamed != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("title = %q after status %d", got.Title, w.Code)
+			}
+		})
+	}
+}
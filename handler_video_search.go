@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+func (cfg *apiConfig) handlerVideosSearch(w http.ResponseWriter, r *http.Request) {
+	userID := authUserID(r)
+
+	query := r.URL.Query()
+	params := database.SearchVideosParams{
+		UserID: userID,
+		Query:  query.Get("q"),
+		Cursor: query.Get("cursor"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		params.Limit, err = strconv.Atoi(limit)
+		if err != nil || params.Limit < 1 || params.Limit > database.MaxVideoPageSize {
+			msg := fmt.Sprintf("limit must be between 1 and %d", database.MaxVideoPageSize)
+			respondWithError(w, http.StatusBadRequest, msg, err)
+			return
+		}
+	}
+
+	page, err := cfg.db.SearchVideos(params)
+	if errors.Is(err, database.ErrEmptySearchQuery) {
+		respondWithError(w, http.StatusBadRequest, "Search query q is required", err)
+		return
+	}
+	if errors.Is(err, database.ErrInvalidCursor) {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search videos", err)
+		return
+	}
+
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	respondWithJSON(w, http.StatusOK, page.Results)
+}
@@ -20,6 +20,10 @@ const (
 	TokenTypeAccess TokenType = "tubely-access"
 )
 
+// AccessTokenAudience is the aud claim of access tokens, so that tokens
+// minted for other services sharing the signing keys aren't accepted here.
+const AccessTokenAudience = "tubely-api"
+
 var ErrNoAuthHeaderIncluded = errors.New("no auth header included in request")
 
 func HashPassword(password string) (string, error) {
@@ -34,50 +38,83 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
+// accessClaims are the claims in an access token. Scope is space-separated,
+// as in OAuth 2.0.
+type accessClaims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope,omitempty"`
+}
+
+// AccessToken is what a valid access token says about its holder.
+type AccessToken struct {
+	// ID is the token's jti claim, which identifies it on the deny-list.
+	ID        string
+	UserID    uuid.UUID
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
 func MakeJWT(
 	userID uuid.UUID,
-	tokenSecret string,
+	keys *KeySet,
 	expiresIn time.Duration,
+	scopes []string,
 ) (string, error) {
-	signingKey := []byte(tokenSecret)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
+	return keys.sign(accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{AccessTokenAudience},
+			ID:        uuid.NewString(),
+		},
+		Scope: strings.Join(scopes, " "),
 	})
-	return token.SignedString(signingKey)
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
+// ValidateJWT checks an access token's signature, expiry, issuer and
+// audience. It doesn't consult the deny-list.
+func ValidateJWT(tokenString string, keys *KeySet) (AccessToken, error) {
+	claimsStruct := accessClaims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&claimsStruct,
-		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
+		keys.verificationKey,
+		jwt.WithValidMethods([]string{
+			jwt.SigningMethodRS256.Alg(),
+			jwt.SigningMethodEdDSA.Alg(),
+			jwt.SigningMethodHS256.Alg(),
+		}),
+		jwt.WithAudience(AccessTokenAudience),
+		jwt.WithIssuer(string(TokenTypeAccess)),
 	)
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
-
-	issuer, err := token.Claims.GetIssuer()
+	id, err := uuid.Parse(userIDString)
 	if err != nil {
-		return uuid.Nil, err
-	}
-	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+		return AccessToken{}, fmt.Errorf("invalid user ID: %w", err)
 	}
 
-	id, err := uuid.Parse(userIDString)
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+	if claimsStruct.ID == "" {
+		return AccessToken{}, errors.New("token has no ID")
+	}
+	if claimsStruct.ExpiresAt == nil {
+		return AccessToken{}, errors.New("token has no expiry")
 	}
-	return id, nil
+
+	return AccessToken{
+		ID:        claimsStruct.ID,
+		UserID:    id,
+		Scopes:    strings.Fields(claimsStruct.Scope),
+		ExpiresAt: claimsStruct.ExpiresAt.Time,
+	}, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -102,6 +139,18 @@ func MakeRefreshToken() (string, error) {
 	return hex.EncodeToString(token), nil
 }
 
+// APIKeyPrefix starts every API key so they're easy to recognise, for
+// example by secret scanners.
+const APIKeyPrefix = "tubely_"
+
+func MakeAPIKey() (string, error) {
+	key, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + key, nil
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if authHeader == "" {
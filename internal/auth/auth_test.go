@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestValidateJWT(t *testing.T) {
+	const secret = "secret"
+	ks, err := NewKeySet(nil, secret)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	userID := uuid.New()
+
+	tests := []struct {
+		name    string
+		edit    func(claims *accessClaims)
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			edit: func(claims *accessClaims) {},
+		},
+		{
+			name: "wrong audience",
+			edit: func(claims *accessClaims) {
+				claims.Audience = jwt.ClaimStrings{"another-service"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "no audience",
+			edit: func(claims *accessClaims) {
+				claims.Audience = nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "wrong issuer",
+			edit: func(claims *accessClaims) {
+				claims.Issuer = "someone-else"
+			},
+			wantErr: true,
+		},
+		{
+			name: "no jti",
+			edit: func(claims *accessClaims) {
+				claims.ID = ""
+			},
+			wantErr: true,
+		},
+		{
+			name: "no expiry",
+			edit: func(claims *accessClaims) {
+				claims.ExpiresAt = nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "expired",
+			edit: func(claims *accessClaims) {
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid subject",
+			edit: func(claims *accessClaims) {
+				claims.Subject = "boots"
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := accessClaims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    string(TokenTypeAccess),
+					IssuedAt:  jwt.NewNumericDate(time.Now()),
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+					Subject:   userID.String(),
+					Audience:  jwt.ClaimStrings{AccessTokenAudience},
+					ID:        "token-id",
+				},
+				Scope: ScopeVideosRead + " " + ScopeVideosWrite,
+			}
+			tt.edit(&claims)
+			token := signTestToken(t, claims, jwt.SigningMethodHS256, "", []byte(secret))
+
+			got, err := ValidateJWT(token, ks)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("ValidateJWT accepted the token")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateJWT: %v", err)
+			}
+			if got.ID != "token-id" || got.UserID != userID || len(got.Scopes) != 2 {
+				t.Errorf("ValidateJWT = %+v", got)
+			}
+		})
+	}
+}
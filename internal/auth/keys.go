@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSAKeyBits = 2048
+
+// SigningKey is an asymmetric key that access tokens can be signed with.
+// It signs new tokens from ActiveFrom until the next key becomes active,
+// and tokens it signed are accepted until RetireAt. RetireAt should be at
+// least one access token lifetime after the next key's ActiveFrom.
+type SigningKey struct {
+	ID         string
+	PrivateKey crypto.Signer
+	ActiveFrom time.Time
+	// RetireAt is zero for keys that haven't been scheduled for retirement.
+	RetireAt time.Time
+}
+
+func (k SigningKey) method() jwt.SigningMethod {
+	if _, ok := k.PrivateKey.Public().(ed25519.PublicKey); ok {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+func (k SigningKey) retired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && !now.Before(k.RetireAt)
+}
+
+// KeySet signs and verifies access tokens. With asymmetric keys, tokens
+// carry the ID of the key that signed them in the kid header and anyone
+// can verify them with the keys published by JWKS. Without any, it falls
+// back to HS256 with a shared secret. When both are configured, HS256
+// tokens are still accepted so switching doesn't log everyone out.
+type KeySet struct {
+	keys   []SigningKey
+	secret []byte
+	now    func() time.Time
+}
+
+// NewKeySet checks keys and returns a KeySet for them. secret may be empty
+// if keys isn't.
+func NewKeySet(keys []SigningKey, secret string) (*KeySet, error) {
+	if len(keys) == 0 && secret == "" {
+		return nil, errors.New("no signing keys or secret")
+	}
+
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("signing key has no ID")
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate signing key ID %q", key.ID)
+		}
+		seen[key.ID] = true
+
+		switch pub := key.PrivateKey.Public().(type) {
+		case ed25519.PublicKey:
+		case *rsa.PublicKey:
+			if pub.N.BitLen() < minRSAKeyBits {
+				return nil, fmt.Errorf("signing key %q is shorter than %d bits", key.ID, minRSAKeyBits)
+			}
+		default:
+			return nil, fmt.Errorf("signing key %q must be RSA or Ed25519", key.ID)
+		}
+		if !key.RetireAt.IsZero() && !key.ActiveFrom.Before(key.RetireAt) {
+			return nil, fmt.Errorf("signing key %q retires before it becomes active", key.ID)
+		}
+	}
+
+	keys = append([]SigningKey(nil), keys...)
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ActiveFrom.Before(keys[j].ActiveFrom)
+	})
+	return &KeySet{
+		keys:   keys,
+		secret: []byte(secret),
+		now:    time.Now,
+	}, nil
+}
+
+// LoadKeySet reads the signing keys listed in a JSON file like
+//
+//	[
+//	  {"kid": "2026-09", "private_key_file": "2026-09.pem", "active_from": "2026-09-01T00:00:00Z", "retire_at": "2026-10-02T00:00:00Z"},
+//	  {"kid": "2026-10", "private_key_file": "2026-10.pem", "active_from": "2026-10-01T00:00:00Z"}
+//	]
+//
+// Key files hold a PEM encoded PKCS #8 or PKCS #1 private key, and relative
+// paths are resolved from the directory of the list. If path is empty, the
+// KeySet only uses secret.
+func LoadKeySet(path, secret string) (*KeySet, error) {
+	if path == "" {
+		return NewKeySet(nil, secret)
+	}
+
+	type keyFile struct {
+		ID             string    `json:"kid"`
+		PrivateKeyFile string    `json:"private_key_file"`
+		ActiveFrom     time.Time `json:"active_from"`
+		RetireAt       time.Time `json:"retire_at"`
+	}
+
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []keyFile
+	err = json.Unmarshal(dat, &files)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s lists no keys", path)
+	}
+
+	keys := make([]SigningKey, 0, len(files))
+	for _, file := range files {
+		keyPath := file.PrivateKeyFile
+		if !filepath.IsAbs(keyPath) {
+			keyPath = filepath.Join(filepath.Dir(path), keyPath)
+		}
+		privateKey, err := readPrivateKey(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", file.ID, err)
+		}
+		keys = append(keys, SigningKey{
+			ID:         file.ID,
+			PrivateKey: privateKey,
+			ActiveFrom: file.ActiveFrom,
+			RetireAt:   file.RetireAt,
+		})
+	}
+	return NewKeySet(keys, secret)
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(dat)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// signingKey returns the key that new tokens are signed with: the one that
+// became active most recently and hasn't been retired.
+func (ks *KeySet) signingKey(now time.Time) (SigningKey, bool) {
+	for i := len(ks.keys) - 1; i >= 0; i-- {
+		key := ks.keys[i]
+		if key.ActiveFrom.After(now) || key.retired(now) {
+			continue
+		}
+		return key, true
+	}
+	return SigningKey{}, false
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	if key, ok := ks.signingKey(ks.now()); ok {
+		token := jwt.NewWithClaims(key.method(), claims)
+		token.Header["kid"] = key.ID
+		return token.SignedString(key.PrivateKey)
+	}
+	if len(ks.secret) == 0 {
+		return "", errors.New("no active signing key")
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ks.secret)
+}
+
+// verificationKey is the jwt.Keyfunc for tokens signed by the set. Keys
+// are accepted from the moment they're listed, before they start signing,
+// so other services can pick them up ahead of rotation.
+func (ks *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		if token.Method != jwt.SigningMethodHS256 || len(ks.secret) == 0 {
+			return nil, errors.New("token has no key ID")
+		}
+		return ks.secret, nil
+	}
+
+	now := ks.now()
+	for _, key := range ks.keys {
+		if key.ID != kid {
+			continue
+		}
+		if key.retired(now) {
+			return nil, fmt.Errorf("signing key %q has been retired", kid)
+		}
+		// Without this check, a token could pick an algorithm that uses
+		// the key differently than intended.
+		if token.Method.Alg() != key.method().Alg() {
+			return nil, fmt.Errorf("signing key %q doesn't use %s", kid, token.Method.Alg())
+		}
+		return key.PrivateKey.Public(), nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys (RFC 8037)
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public halves of every key that hasn't been retired,
+// including ones that haven't started signing yet. The HS256 secret is
+// never published.
+func (ks *KeySet) JWKS() JWKSet {
+	now := ks.now()
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		if key.retired(now) {
+			continue
+		}
+		jwk := JWK{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.method().Alg(),
+		}
+		switch pub := key.PrivateKey.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
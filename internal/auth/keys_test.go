@@ -0,0 +1,401 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var testNow = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+var (
+	rsaKeyOnce sync.Once
+	rsaKey     *rsa.PrivateKey
+)
+
+// testRSAKey returns an RSA key shared by the tests, since generating one
+// is slow.
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	rsaKeyOnce.Do(func() {
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, minRSAKeyBits)
+		if err != nil {
+			panic(err)
+		}
+	})
+	return rsaKey
+}
+
+func testEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// newTestKeySet returns a KeySet whose clock is fixed at now.
+func newTestKeySet(t *testing.T, keys []SigningKey, secret string, now time.Time) *KeySet {
+	t.Helper()
+	ks, err := NewKeySet(keys, secret)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	ks.now = func() time.Time { return now }
+	return ks
+}
+
+// testClaims are valid access token claims as of testNow.
+func testClaims() accessClaims {
+	return accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(testNow),
+			ExpiresAt: jwt.NewNumericDate(testNow.Add(time.Hour)),
+			Subject:   uuid.NewString(),
+			Audience:  jwt.ClaimStrings{AccessTokenAudience},
+			ID:        uuid.NewString(),
+		},
+	}
+}
+
+// signTestToken signs claims with method and key, setting the kid header
+// if kid isn't empty.
+func signTestToken(t *testing.T, claims jwt.Claims, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Couldn't sign token: %v", err)
+	}
+	return s
+}
+
+func TestNewKeySet(t *testing.T) {
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		keys    []SigningKey
+		secret  string
+		wantErr string
+	}{
+		{
+			name:    "nothing configured",
+			wantErr: "no signing keys or secret",
+		},
+		{
+			name:   "secret only",
+			secret: "secret",
+		},
+		{
+			name:    "missing ID",
+			keys:    []SigningKey{{PrivateKey: testEd25519Key(t)}},
+			wantErr: "has no ID",
+		},
+		{
+			name: "duplicate ID",
+			keys: []SigningKey{
+				{ID: "a", PrivateKey: testEd25519Key(t)},
+				{ID: "a", PrivateKey: testEd25519Key(t)},
+			},
+			wantErr: "duplicate",
+		},
+		{
+			name:    "short RSA key",
+			keys:    []SigningKey{{ID: "a", PrivateKey: smallKey}},
+			wantErr: "shorter than",
+		},
+		{
+			name: "retires before active",
+			keys: []SigningKey{{
+				ID:         "a",
+				PrivateKey: testEd25519Key(t),
+				ActiveFrom: testNow,
+				RetireAt:   testNow.Add(-time.Hour),
+			}},
+			wantErr: "retires before",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeySet(tt.keys, tt.secret)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewKeySet: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewKeySet: err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSigningKeySelection(t *testing.T) {
+	// "old" hands over to "current" an hour before testNow and keeps
+	// verifying for an hour after. "next" takes over an hour after testNow.
+	keys := []SigningKey{
+		{ID: "next", PrivateKey: testEd25519Key(t), ActiveFrom: testNow.Add(time.Hour)},
+		{ID: "old", PrivateKey: testEd25519Key(t), ActiveFrom: testNow.Add(-48 * time.Hour), RetireAt: testNow.Add(time.Hour)},
+		{ID: "current", PrivateKey: testRSAKey(t), ActiveFrom: testNow.Add(-time.Hour), RetireAt: testNow.Add(48 * time.Hour)},
+	}
+	ks := newTestKeySet(t, keys, "", testNow)
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		wantKID string
+	}{
+		{name: "before any key is active", now: testNow.Add(-72 * time.Hour)},
+		{name: "only the oldest is active", now: testNow.Add(-24 * time.Hour), wantKID: "old"},
+		{name: "newest active key wins", now: testNow, wantKID: "current"},
+		{name: "at ActiveFrom", now: testNow.Add(time.Hour), wantKID: "next"},
+		{name: "just before ActiveFrom", now: testNow.Add(time.Hour - time.Nanosecond), wantKID: "current"},
+		{name: "retired keys are skipped", now: testNow.Add(72 * time.Hour), wantKID: "next"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := ks.signingKey(tt.now)
+			if tt.wantKID == "" {
+				if ok {
+					t.Errorf("signingKey = %q, want none", key.ID)
+				}
+				return
+			}
+			if !ok || key.ID != tt.wantKID {
+				t.Errorf("signingKey = %q, %v, want %q", key.ID, ok, tt.wantKID)
+			}
+		})
+	}
+
+	// Once every key has retired there's nothing to sign with.
+	retired := newTestKeySet(t, keys[1:2], "", testNow.Add(2*time.Hour))
+	_, err := retired.sign(testClaims())
+	if err == nil {
+		t.Error("sign with only a retired key succeeded")
+	}
+}
+
+func TestVerificationKey(t *testing.T) {
+	rsaSigner := testRSAKey(t)
+	edSigner := testEd25519Key(t)
+	retiredSigner := testEd25519Key(t)
+	keys := []SigningKey{
+		{ID: "rsa", PrivateKey: rsaSigner, ActiveFrom: testNow.Add(-time.Hour)},
+		{ID: "ed", PrivateKey: edSigner, ActiveFrom: testNow.Add(time.Hour)},
+		{ID: "retired", PrivateKey: retiredSigner, ActiveFrom: testNow.Add(-48 * time.Hour), RetireAt: testNow.Add(-time.Minute)},
+	}
+	const secret = "secret"
+
+	tests := []struct {
+		name   string
+		token  func(t *testing.T) string
+		secret string
+		// noKeys leaves the asymmetric keys out of the set.
+		noKeys  bool
+		wantErr bool
+	}{
+		{
+			name: "RSA key",
+			token: func(t *testing.T) string {
+				return signTestToken(t, testClaims(), jwt.SigningMethodRS256, "rsa", rsaSigner)
+			},
+		},
+		{
+			name: "key that isn't signing yet",
+			token: func(t *testing.T) string {
+				return signTestToken(t, testClaims(), jwt.SigningMethodEdDSA, "ed", edSigner)
+			},
+		},
+		{
+			name: "retired key",
+			token: func(t *testing.T) string {
+				return signTestToken(t, testClaims(), jwt.SigningMethodEdDSA, "retired", retiredSigner)
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown key ID",
+			token: func(t *testing.T) string {
+				return signTestToken(t, testClaims(), jwt.SigningMethodEdDSA, "missing", edSigner)
+			},
+			wantErr: true,
+		},
+		{
+			name: "HS256 token with an RSA key ID",
+			token: func(t *testing.T) string {
+				return signTestToken(t, testClaims(), jwt.SigningMethodHS256, "rsa", []byte(secret))
+			},
+			secret:  secret,
+			wantErr: true,
+		},
+		{
+			name: "EdDSA token with an RSA key ID",
+			token: func(t *testing.T) string {
+				return signTestToken(t, testClaims(), jwt.SigningMethodEdDSA, "rsa", edSigner)
+			},
+			wantErr: true,
+		},
+		{
+			name: "HS256 with a secret configured",
+			token: func(t *testing.T) string {
+				return signTestToken(t, testClaims(), jwt.SigningMethodHS256, "", []byte(secret))
+			},
+			secret: secret,
+		},
+		{
+			name: "HS256 with only a secret",
+			token: func(t *testing.T) string {
+				return signTestToken(t, testClaims(), jwt.SigningMethodHS256, "", []byte(secret))
+			},
+			secret: secret,
+			noKeys: true,
+		},
+		{
+			name: "HS256 without a secret",
+			token: func(t *testing.T) string {
+				return signTestToken(t, testClaims(), jwt.SigningMethodHS256, "", []byte(secret))
+			},
+			wantErr: true,
+		},
+		{
+			name: "HS256 with the wrong secret",
+			token: func(t *testing.T) string {
+				return signTestToken(t, testClaims(), jwt.SigningMethodHS256, "", []byte("other"))
+			},
+			secret:  secret,
+			wantErr: true,
+		},
+		{
+			name: "RSA token without a key ID",
+			token: func(t *testing.T) string {
+				return signTestToken(t, testClaims(), jwt.SigningMethodRS256, "", rsaSigner)
+			},
+			secret:  secret,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setKeys := keys
+			if tt.noKeys {
+				setKeys = nil
+			}
+			ks := newTestKeySet(t, setKeys, tt.secret, testNow)
+
+			_, err := jwt.Parse(tt.token(t), ks.verificationKey, jwt.WithTimeFunc(ks.now))
+			if tt.wantErr && err == nil {
+				t.Error("token was accepted")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("token was rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaSigner := testRSAKey(t)
+	edSigner := testEd25519Key(t)
+	keys := []SigningKey{
+		{ID: "rsa", PrivateKey: rsaSigner, ActiveFrom: testNow.Add(-time.Hour)},
+		{ID: "ed", PrivateKey: edSigner, ActiveFrom: testNow.Add(time.Hour)},
+		{ID: "retired", PrivateKey: testEd25519Key(t), ActiveFrom: testNow.Add(-48 * time.Hour), RetireAt: testNow},
+	}
+	ks := newTestKeySet(t, keys, "secret", testNow)
+
+	set := ks.JWKS()
+	byID := map[string]JWK{}
+	for _, jwk := range set.Keys {
+		byID[jwk.KeyID] = jwk
+	}
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS has keys %v, want rsa and ed", set.Keys)
+	}
+
+	rsaJWK := byID["rsa"]
+	if rsaJWK.KeyType != "RSA" || rsaJWK.Algorithm != "RS256" || rsaJWK.Use != "sig" {
+		t.Errorf("RSA JWK = %+v", rsaJWK)
+	}
+	// 65537, big-endian, without leading zeros.
+	if rsaJWK.E != "AQAB" {
+		t.Errorf("RSA JWK e = %q, want AQAB", rsaJWK.E)
+	}
+	if rsaJWK.N == "" || rsaJWK.Curve != "" || rsaJWK.X != "" {
+		t.Errorf("RSA JWK = %+v", rsaJWK)
+	}
+
+	edJWK := byID["ed"]
+	if edJWK.KeyType != "OKP" || edJWK.Curve != "Ed25519" || edJWK.Algorithm != "EdDSA" || edJWK.Use != "sig" {
+		t.Errorf("Ed25519 JWK = %+v", edJWK)
+	}
+	if edJWK.N != "" || edJWK.E != "" {
+		t.Errorf("Ed25519 JWK = %+v", edJWK)
+	}
+
+	// The secret must never be published.
+	dat, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(dat), "secret") || strings.Contains(string(dat), `"oct"`) {
+		t.Errorf("JWKS publishes the HS256 secret: %s", dat)
+	}
+
+	// The published keys verify tokens from the set.
+	token, err := ks.sign(testClaims())
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	_, err = jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return jwkPublicKey(t, byID[token.Header["kid"].(string)]), nil
+	}, jwt.WithTimeFunc(ks.now))
+	if err != nil {
+		t.Errorf("token doesn't verify with the published key: %v", err)
+	}
+}
+
+// jwkPublicKey decodes an RSA JWK back into a public key.
+func jwkPublicKey(t *testing.T, jwk JWK) *rsa.PublicKey {
+	t.Helper()
+	n, err := base64RawURLToInt(jwk.N)
+	if err != nil {
+		t.Fatalf("Couldn't decode n: %v", err)
+	}
+	e, err := base64RawURLToInt(jwk.E)
+	if err != nil {
+		t.Fatalf("Couldn't decode e: %v", err)
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}
+}
+
+func base64RawURLToInt(s string) (*big.Int, error) {
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(dat), nil
+}
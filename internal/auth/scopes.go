@@ -0,0 +1,33 @@
+package auth
+
+import "slices"
+
+// Scopes limit what an access token or API key can do. Routes name the
+// scope they need; ScopeAdmin satisfies all of them.
+const (
+	ScopeVideosRead   = "videos:read"
+	ScopeVideosWrite  = "videos:write"
+	ScopeVideosDelete = "videos:delete"
+	ScopeUploadsWrite = "uploads:write"
+	ScopeAccountWrite = "account:write"
+	ScopeAdmin        = "admin"
+)
+
+// UserScopes are granted to a user who logs in with their password.
+var UserScopes = []string{
+	ScopeVideosRead,
+	ScopeVideosWrite,
+	ScopeVideosDelete,
+	ScopeUploadsWrite,
+	ScopeAccountWrite,
+}
+
+// IsValidScope reports whether scope is one of the scopes defined above.
+func IsValidScope(scope string) bool {
+	return scope == ScopeAdmin || slices.Contains(UserScopes, scope)
+}
+
+// HasScope reports whether scopes grants scope.
+func HasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, ScopeAdmin) || slices.Contains(scopes, scope)
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey is a key as its owner sees it. The key itself is only known when
+// it's created.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type CreateAPIKeyParams struct {
+	UserID uuid.UUID
+	Name   string
+	// Key is the raw key handed to the user. Only its hash and the first
+	// few characters are stored.
+	Key    string
+	Scopes []string
+}
+
+// apiKeyPrefixLength is how much of a key is kept in the clear to identify
+// it in lists.
+const apiKeyPrefixLength = 12
+
+const apiKeyColumns = `
+	id, created_at, user_id, name, prefix, scopes, last_used_at, revoked_at
+`
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var key APIKey
+	var scopes string
+	err := row.Scan(
+		&key.ID,
+		&key.CreatedAt,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&scopes,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+	)
+	key.Scopes = strings.Fields(scopes)
+	return key, err
+}
+
+func (c sqlStore) CreateAPIKey(params CreateAPIKeyParams) (APIKey, error) {
+	id := uuid.New()
+	prefix := params.Key
+	if len(prefix) > apiKeyPrefixLength {
+		prefix = prefix[:apiKeyPrefixLength]
+	}
+	query := `
+	INSERT INTO api_keys (
+		id,
+		created_at,
+		user_id,
+		name,
+		key_hash,
+		prefix,
+		scopes
+	) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := c.db.Exec(query, id, timestamp(), params.UserID, params.Name, hashToken(params.Key), prefix, strings.Join(params.Scopes, " "))
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	return scanAPIKey(c.db.QueryRow(`SELECT`+apiKeyColumns+`FROM api_keys WHERE id = ?`, id))
+}
+
+// GetAPIKeys returns the user's keys that haven't been revoked, newest
+// first.
+func (c sqlStore) GetAPIKeys(userID uuid.UUID) ([]APIKey, error) {
+	query := `
+	SELECT` + apiKeyColumns + `
+	FROM api_keys
+	WHERE user_id = ? AND revoked_at IS NULL
+	ORDER BY created_at DESC
+	`
+	rows, err := c.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByKey looks a key up by its raw value. It returns ErrNotFound if
+// the key doesn't exist or has been revoked.
+func (c sqlStore) GetAPIKeyByKey(key string) (APIKey, error) {
+	query := `
+	SELECT` + apiKeyColumns + `
+	FROM api_keys
+	WHERE key_hash = ? AND revoked_at IS NULL
+	`
+	apiKey, err := scanAPIKey(c.db.QueryRow(query, hashToken(key)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return APIKey{}, ErrNotFound
+		}
+		return APIKey{}, err
+	}
+	return apiKey, nil
+}
+
+// TouchAPIKey records that the key was just used.
+func (c sqlStore) TouchAPIKey(id uuid.UUID) error {
+	_, err := c.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", timestamp(), id)
+	return err
+}
+
+// RevokeAPIKey revokes one of the user's keys. It returns ErrNotFound if
+// the user has no live key with that ID.
+func (c sqlStore) RevokeAPIKey(userID, id uuid.UUID) error {
+	query := `
+	UPDATE api_keys
+	SET revoked_at = ?
+	WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`
+	result, err := c.db.Exec(query, timestamp(), id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,876 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// postgresDSNEnv names a Postgres database the conformance tests may wipe.
+// They only run against SQLite when it's unset.
+const postgresDSNEnv = "TUBELY_TEST_POSTGRES_DSN"
+
+// testBackends returns a constructor for an empty, migrated Client for each
+// backend available to the tests.
+func testBackends(t *testing.T) map[string]func(t *testing.T) Client {
+	backends := map[string]func(t *testing.T) Client{
+		DriverSQLite: func(t *testing.T) Client {
+			c, err := Open(DriverSQLite, filepath.Join(t.TempDir(), "tubely.db"))
+			if err != nil {
+				t.Fatalf("Couldn't open SQLite database: %v", err)
+			}
+			t.Cleanup(func() { c.Close() })
+			return c
+		},
+	}
+
+	if dsn := os.Getenv(postgresDSNEnv); dsn != "" {
+		backends[DriverPostgres] = func(t *testing.T) Client {
+			c, err := Open(DriverPostgres, dsn)
+			if err != nil {
+				t.Fatalf("Couldn't open Postgres database: %v", err)
+			}
+			t.Cleanup(func() { c.Close() })
+			err = c.Reset()
+			if err != nil {
+				t.Fatalf("Couldn't reset Postgres database: %v", err)
+			}
+			return c
+		}
+	} else {
+		t.Logf("%s is not set, skipping Postgres", postgresDSNEnv)
+	}
+	return backends
+}
+
+// TestClientConformance runs the same checks against every backend so the
+// SQLite and Postgres implementations can't drift apart.
+func TestClientConformance(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, c Client)
+	}{
+		{"users", testUsers},
+		{"delete user cascades", testDeleteUserCascades},
+		{"videos", testVideos},
+		{"conditional update", testUpdateVideoIfUnmodified},
+		{"trash", testTrash},
+		{"list videos", testListVideos},
+		{"search videos", testSearchVideos},
+		{"search pagination", testSearchVideosPagination},
+		{"tags", testTags},
+		{"playlists", testPlaylists},
+		{"playlists with trashed videos", testPlaylistsWithTrashedVideos},
+		{"refresh tokens", testRefreshTokens},
+		{"sessions", testSessions},
+		{"api keys", testAPIKeys},
+		{"denied access tokens", testDeniedAccessTokens},
+	}
+
+	for driver, open := range testBackends(t) {
+		t.Run(driver, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.run(t, open(t))
+				})
+			}
+		})
+	}
+}
+
+func createTestUser(t *testing.T, c Client) *User {
+	t.Helper()
+	user, err := c.CreateUser(CreateUserParams{
+		Email:    uuid.NewString() + "@example.com",
+		Password: "hash",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+func createTestVideo(t *testing.T, c Client, userID uuid.UUID, title string) Video {
+	t.Helper()
+	video, err := c.CreateVideo(CreateVideoParams{
+		Title:       title,
+		Description: "A video about " + title,
+		UserID:      userID,
+	})
+	if err != nil {
+		t.Fatalf("CreateVideo: %v", err)
+	}
+	return video
+}
+
+func testUsers(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	if user.Plan == "" {
+		t.Error("new user has no plan")
+	}
+
+	got, err := c.GetUser(user.ID)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if got.Email != user.Email {
+		t.Errorf("GetUser email = %q, want %q", got.Email, user.Email)
+	}
+	byEmail, err := c.GetUserByEmail(user.Email)
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %v", err)
+	}
+	if byEmail.ID != user.ID {
+		t.Errorf("GetUserByEmail ID = %v, want %v", byEmail.ID, user.ID)
+	}
+
+	_, err = c.CreateUser(CreateUserParams{Email: user.Email, Password: "hash"})
+	if err == nil {
+		t.Error("CreateUser with a taken email succeeded")
+	}
+
+	avatarURL := "/assets/avatar.png"
+	updated, err := c.UpdateUserProfile(user.ID, UpdateUserProfileParams{DisplayName: "Boots", AvatarURL: &avatarURL, AvatarSize: 50})
+	if err != nil {
+		t.Fatalf("UpdateUserProfile: %v", err)
+	}
+	if updated.DisplayName != "Boots" || updated.AvatarURL == nil || *updated.AvatarURL != avatarURL || updated.AvatarSize != 50 {
+		t.Errorf("UpdateUserProfile = %q, %v, %d", updated.DisplayName, updated.AvatarURL, updated.AvatarSize)
+	}
+	usage, err := c.GetStorageUsage(user.ID)
+	if err != nil {
+		t.Fatalf("GetStorageUsage: %v", err)
+	}
+	if want := (StorageUsage{AvatarBytes: 50, TotalBytes: 50}); usage != want {
+		t.Errorf("GetStorageUsage = %+v, want %+v", usage, want)
+	}
+
+	if updated.IsAdmin {
+		t.Error("new user is an admin")
+	}
+	err = c.SetUserAdmin(user.ID, true)
+	if err != nil {
+		t.Fatalf("SetUserAdmin: %v", err)
+	}
+	updated, err = c.GetUser(user.ID)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if !updated.IsAdmin {
+		t.Error("SetUserAdmin didn't make the user an admin")
+	}
+	err = c.SetUserAdmin(uuid.New(), true)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetUserAdmin for a missing user: err = %v, want ErrNotFound", err)
+	}
+
+	_, err = c.GetUser(uuid.New())
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUser for a missing user: err = %v, want ErrNotFound", err)
+	}
+	_, err = c.GetUserByEmail("nobody@example.com")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUserByEmail for a missing user: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testDeleteUserCascades(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	video := createTestVideo(t, c, user.ID, "cooking")
+	_, err := c.CreateRefreshToken(CreateRefreshTokenParams{
+		Token:     "refresh",
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+
+	err = c.DeleteUser(user.ID)
+	if err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	_, err = c.GetVideo(video.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetVideo after deleting its owner: err = %v, want ErrNotFound", err)
+	}
+	_, err = c.GetRefreshToken("refresh")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetRefreshToken after deleting its owner: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testVideos(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	video := createTestVideo(t, c, user.ID, "cooking")
+	if video.Visibility != VisibilityPrivate {
+		t.Errorf("new video visibility = %q, want %q", video.Visibility, VisibilityPrivate)
+	}
+	if video.VideoURL != nil || video.ThumbnailURL != nil || video.Orientation != nil {
+		t.Error("new video has files")
+	}
+
+	videoURL := "https://cdn.example.com/landscape/abc.mp4"
+	orientation := "landscape"
+	video.Title = "cooking tips"
+	video.VideoURL = &videoURL
+	video.Orientation = &orientation
+	video.VideoSize = 1234
+	video.Visibility = VisibilityPublic
+	updated, err := c.UpdateVideo(video)
+	if err != nil {
+		t.Fatalf("UpdateVideo: %v", err)
+	}
+	if updated.Title != "cooking tips" || updated.Visibility != VisibilityPublic || updated.VideoSize != 1234 {
+		t.Errorf("UpdateVideo = %+v", updated)
+	}
+	if updated.VideoURL == nil || *updated.VideoURL != videoURL {
+		t.Errorf("UpdateVideo video URL = %v, want %q", updated.VideoURL, videoURL)
+	}
+	if updated.UpdatedAt.Before(video.UpdatedAt) {
+		t.Errorf("UpdateVideo moved updated_at back from %v to %v", video.UpdatedAt, updated.UpdatedAt)
+	}
+
+	videos, err := c.GetVideos(user.ID)
+	if err != nil {
+		t.Fatalf("GetVideos: %v", err)
+	}
+	if len(videos) != 1 || videos[0].ID != video.ID {
+		t.Errorf("GetVideos = %v, want just %v", videos, video.ID)
+	}
+
+	err = c.DeleteVideo(video.ID)
+	if err != nil {
+		t.Fatalf("DeleteVideo: %v", err)
+	}
+	_, err = c.GetVideo(video.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetVideo after DeleteVideo: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testUpdateVideoIfUnmodified(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	video := createTestVideo(t, c, user.ID, "cooking")
+
+	video.Title = "first"
+	updated, err := c.UpdateVideoIfUnmodified(video)
+	if err != nil {
+		t.Fatalf("UpdateVideoIfUnmodified: %v", err)
+	}
+
+	// video still holds the updated_at from before the first update.
+	video.Title = "second"
+	_, err = c.UpdateVideoIfUnmodified(video)
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("UpdateVideoIfUnmodified with a stale copy: err = %v, want ErrConflict", err)
+	}
+
+	updated.Title = "third"
+	_, err = c.UpdateVideoIfUnmodified(updated)
+	if err != nil {
+		t.Errorf("UpdateVideoIfUnmodified with a fresh copy: %v", err)
+	}
+}
+
+func testTrash(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	video := createTestVideo(t, c, user.ID, "cooking")
+	video.VideoSize = 100
+	video, err := c.UpdateVideo(video)
+	if err != nil {
+		t.Fatalf("UpdateVideo: %v", err)
+	}
+
+	err = c.TrashVideo(video.ID)
+	if err != nil {
+		t.Fatalf("TrashVideo: %v", err)
+	}
+	_, err = c.GetVideo(video.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetVideo for a trashed video: err = %v, want ErrNotFound", err)
+	}
+	trashed, err := c.GetTrashedVideo(video.ID)
+	if err != nil {
+		t.Fatalf("GetTrashedVideo: %v", err)
+	}
+	if trashed.DeletedAt == nil {
+		t.Error("trashed video has no deleted_at")
+	}
+
+	usage, err := c.GetStorageUsage(user.ID)
+	if err != nil {
+		t.Fatalf("GetStorageUsage: %v", err)
+	}
+	want := StorageUsage{TrashBytes: 100, TotalBytes: 100}
+	if usage != want {
+		t.Errorf("GetStorageUsage = %+v, want %+v", usage, want)
+	}
+
+	before, err := c.GetVideosTrashedBefore(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetVideosTrashedBefore: %v", err)
+	}
+	if len(before) != 1 || before[0].ID != video.ID {
+		t.Errorf("GetVideosTrashedBefore = %v, want just %v", before, video.ID)
+	}
+	before, err = c.GetVideosTrashedBefore(time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("GetVideosTrashedBefore: %v", err)
+	}
+	if len(before) != 0 {
+		t.Errorf("GetVideosTrashedBefore an earlier cutoff = %v, want none", before)
+	}
+
+	restored, err := c.RestoreVideo(video.ID)
+	if err != nil {
+		t.Fatalf("RestoreVideo: %v", err)
+	}
+	if restored.DeletedAt != nil {
+		t.Error("restored video still has deleted_at")
+	}
+	_, err = c.GetTrashedVideo(video.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTrashedVideo after restoring: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testListVideos(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	other := createTestUser(t, c)
+	want := []uuid.UUID{}
+	for _, title := range []string{"alpha", "bravo", "charlie", "delta", "echo"} {
+		want = append(want, createTestVideo(t, c, user.ID, title).ID)
+	}
+	createTestVideo(t, c, other.ID, "foxtrot")
+
+	params := ListVideosParams{UserID: user.ID, Limit: 2, Sort: VideoSortTitle}
+	got := []uuid.UUID{}
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("ListVideos never ran out of pages")
+		}
+		page, err := c.ListVideos(params)
+		if err != nil {
+			t.Fatalf("ListVideos: %v", err)
+		}
+		for _, video := range page.Videos {
+			got = append(got, video.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ListVideos by title = %v, want %v", got, want)
+	}
+
+	_, err := c.ListVideos(ListVideosParams{UserID: user.ID, Cursor: "not a cursor"})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("ListVideos with a bad cursor: err = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func testSearchVideos(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	other := createTestUser(t, c)
+	match := createTestVideo(t, c, user.ID, "Baking bread")
+	trashed := createTestVideo(t, c, user.ID, "Baking cake")
+	createTestVideo(t, c, user.ID, "Gardening")
+	createTestVideo(t, c, other.ID, "Baking pies")
+	err := c.TrashVideo(trashed.ID)
+	if err != nil {
+		t.Fatalf("TrashVideo: %v", err)
+	}
+
+	page, err := c.SearchVideos(SearchVideosParams{UserID: user.ID, Query: "baking"})
+	if err != nil {
+		t.Fatalf("SearchVideos: %v", err)
+	}
+	if len(page.Results) != 1 || page.Results[0].ID != match.ID {
+		t.Fatalf("SearchVideos = %v, want just %v", page.Results, match.ID)
+	}
+	if page.Results[0].TitleHighlight != HighlightStart+"Baking"+HighlightEnd+" bread" {
+		t.Errorf("title highlight = %q", page.Results[0].TitleHighlight)
+	}
+
+	_, err = c.SearchVideos(SearchVideosParams{UserID: user.ID, Query: "  "})
+	if !errors.Is(err, ErrEmptySearchQuery) {
+		t.Errorf("SearchVideos with a blank query: err = %v, want ErrEmptySearchQuery", err)
+	}
+}
+
+func testSearchVideosPagination(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	want := []uuid.UUID{}
+	// Equal ranks make the id tiebreak do the work.
+	for range 5 {
+		want = append(want, createTestVideo(t, c, user.ID, "baking").ID)
+	}
+	slices.SortFunc(want, func(a, b uuid.UUID) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	params := SearchVideosParams{UserID: user.ID, Query: "baking", Limit: 2}
+	got := []uuid.UUID{}
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("SearchVideos never ran out of pages")
+		}
+		page, err := c.SearchVideos(params)
+		if err != nil {
+			t.Fatalf("SearchVideos: %v", err)
+		}
+		for _, result := range page.Results {
+			got = append(got, result.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("SearchVideos pages = %v, want %v", got, want)
+	}
+
+	_, err := c.SearchVideos(SearchVideosParams{UserID: user.ID, Query: "baking", Cursor: "bm90IGEgY3Vyc29y"})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("SearchVideos with a bad cursor: err = %v, want ErrInvalidCursor", err)
+	}
+}
+
+// VACUUM may renumber the rowids of a table without an INTEGER PRIMARY KEY,
+// so the search index mustn't rely on them.
+func TestSQLiteSearchAfterVacuum(t *testing.T) {
+	c, err := NewSQLiteClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteClient: %v", err)
+	}
+	defer c.Close()
+	err = c.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	user := createTestUser(t, c)
+	titles := []string{"apple", "banana", "cherry", "damson", "elderberry"}
+	videos := map[string]Video{}
+	for _, title := range titles {
+		videos[title] = createTestVideo(t, c, user.ID, title)
+	}
+	for _, title := range titles[:3] {
+		err = c.DeleteVideo(videos[title].ID)
+		if err != nil {
+			t.Fatalf("DeleteVideo: %v", err)
+		}
+	}
+	_, err = c.db.Exec("VACUUM")
+	if err != nil {
+		t.Fatalf("VACUUM: %v", err)
+	}
+
+	for _, title := range titles[3:] {
+		page, err := c.SearchVideos(SearchVideosParams{UserID: user.ID, Query: title})
+		if err != nil {
+			t.Fatalf("SearchVideos: %v", err)
+		}
+		if len(page.Results) != 1 || page.Results[0].ID != videos[title].ID {
+			t.Errorf("SearchVideos(%s) = %v, want just %v", title, page.Results, videos[title].ID)
+		}
+	}
+
+	video := videos["damson"]
+	video.Title = "plum"
+	_, err = c.UpdateVideo(video)
+	if err != nil {
+		t.Fatalf("UpdateVideo: %v", err)
+	}
+	page, err := c.SearchVideos(SearchVideosParams{UserID: user.ID, Query: "plum"})
+	if err != nil {
+		t.Fatalf("SearchVideos: %v", err)
+	}
+	if len(page.Results) != 1 || page.Results[0].ID != video.ID {
+		t.Errorf("SearchVideos(plum) after renaming = %v, want just %v", page.Results, video.ID)
+	}
+}
+
+func testTags(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	first := createTestVideo(t, c, user.ID, "cooking")
+	second := createTestVideo(t, c, user.ID, "baking")
+
+	tags, err := c.SetVideoTags(first, []string{"  Cooking   Tips", "food", "cooking tips"})
+	if err != nil {
+		t.Fatalf("SetVideoTags: %v", err)
+	}
+	if want := []string{"cooking tips", "food"}; !slices.Equal(tags, want) {
+		t.Errorf("SetVideoTags = %v, want %v", tags, want)
+	}
+	_, err = c.SetVideoTags(second, []string{"food"})
+	if err != nil {
+		t.Fatalf("SetVideoTags: %v", err)
+	}
+
+	counts, err := c.GetTags(user.ID)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	want := []TagCount{{Name: "food", Count: 2}, {Name: "cooking tips", Count: 1}}
+	if !slices.Equal(counts, want) {
+		t.Errorf("GetTags = %v, want %v", counts, want)
+	}
+
+	err = c.RemoveVideoTag(first, "Cooking Tips")
+	if err != nil {
+		t.Fatalf("RemoveVideoTag: %v", err)
+	}
+	tags, err = c.GetVideoTags(first.ID)
+	if err != nil {
+		t.Fatalf("GetVideoTags: %v", err)
+	}
+	if want := []string{"food"}; !slices.Equal(tags, want) {
+		t.Errorf("GetVideoTags after removing a tag = %v, want %v", tags, want)
+	}
+	err = c.RemoveVideoTag(first, "cooking tips")
+	if !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("RemoveVideoTag for a missing tag: err = %v, want ErrTagNotFound", err)
+	}
+}
+
+func testPlaylists(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	playlist, err := c.CreatePlaylist(CreatePlaylistParams{Title: "Favorites", UserID: user.ID})
+	if err != nil {
+		t.Fatalf("CreatePlaylist: %v", err)
+	}
+	a := createTestVideo(t, c, user.ID, "a")
+	b := createTestVideo(t, c, user.ID, "b")
+	d := createTestVideo(t, c, user.ID, "d")
+
+	for _, id := range []uuid.UUID{a.ID, b.ID, d.ID} {
+		err = c.AddPlaylistEntry(playlist.ID, id, -1)
+		if err != nil {
+			t.Fatalf("AddPlaylistEntry: %v", err)
+		}
+	}
+	err = c.AddPlaylistEntry(playlist.ID, a.ID, -1)
+	if !errors.Is(err, ErrAlreadyInPlaylist) {
+		t.Errorf("AddPlaylistEntry twice: err = %v, want ErrAlreadyInPlaylist", err)
+	}
+
+	err = c.MovePlaylistEntry(playlist.ID, d.ID, 0)
+	if err != nil {
+		t.Fatalf("MovePlaylistEntry: %v", err)
+	}
+	err = c.RemovePlaylistEntry(playlist.ID, a.ID)
+	if err != nil {
+		t.Fatalf("RemovePlaylistEntry: %v", err)
+	}
+	err = c.RemovePlaylistEntry(playlist.ID, a.ID)
+	if !errors.Is(err, ErrPlaylistEntryNotFound) {
+		t.Errorf("RemovePlaylistEntry twice: err = %v, want ErrPlaylistEntryNotFound", err)
+	}
+
+	entries, err := c.GetPlaylistEntries(playlist.ID)
+	if err != nil {
+		t.Fatalf("GetPlaylistEntries: %v", err)
+	}
+	got := []uuid.UUID{}
+	for i, entry := range entries {
+		if entry.Position != i {
+			t.Errorf("entry %d has position %d", i, entry.Position)
+		}
+		got = append(got, entry.Video.ID)
+	}
+	if want := []uuid.UUID{d.ID, b.ID}; !slices.Equal(got, want) {
+		t.Errorf("GetPlaylistEntries = %v, want %v", got, want)
+	}
+
+	err = c.DeletePlaylist(playlist.ID)
+	if err != nil {
+		t.Fatalf("DeletePlaylist: %v", err)
+	}
+	_, err = c.GetPlaylist(playlist.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPlaylist after DeletePlaylist: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testPlaylistsWithTrashedVideos(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	playlist, err := c.CreatePlaylist(CreatePlaylistParams{Title: "Favorites", UserID: user.ID})
+	if err != nil {
+		t.Fatalf("CreatePlaylist: %v", err)
+	}
+	a := createTestVideo(t, c, user.ID, "a")
+	b := createTestVideo(t, c, user.ID, "b")
+	d := createTestVideo(t, c, user.ID, "d")
+	for _, id := range []uuid.UUID{a.ID, b.ID, d.ID} {
+		err = c.AddPlaylistEntry(playlist.ID, id, -1)
+		if err != nil {
+			t.Fatalf("AddPlaylistEntry: %v", err)
+		}
+	}
+	err = c.TrashVideo(a.ID)
+	if err != nil {
+		t.Fatalf("TrashVideo: %v", err)
+	}
+
+	// Position 1 is counted among the visible entries, b and d.
+	err = c.MovePlaylistEntry(playlist.ID, b.ID, 1)
+	if err != nil {
+		t.Fatalf("MovePlaylistEntry: %v", err)
+	}
+	assertPlaylist := func(want ...uuid.UUID) {
+		t.Helper()
+		entries, err := c.GetPlaylistEntries(playlist.ID)
+		if err != nil {
+			t.Fatalf("GetPlaylistEntries: %v", err)
+		}
+		got := []uuid.UUID{}
+		for i, entry := range entries {
+			if entry.Position != i {
+				t.Errorf("entry %d has position %d", i, entry.Position)
+			}
+			got = append(got, entry.Video.ID)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("GetPlaylistEntries = %v, want %v", got, want)
+		}
+	}
+	assertPlaylist(d.ID, b.ID)
+
+	_, err = c.RestoreVideo(a.ID)
+	if err != nil {
+		t.Fatalf("RestoreVideo: %v", err)
+	}
+	assertPlaylist(d.ID, b.ID, a.ID)
+}
+
+func testRefreshTokens(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	expiresAt := time.Now().Add(time.Hour)
+	first, err := c.CreateRefreshToken(CreateRefreshTokenParams{
+		Token:     "first",
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	if first.FamilyID == uuid.Nil {
+		t.Error("CreateRefreshToken didn't start a family")
+	}
+	if first.UserID != user.ID {
+		t.Errorf("CreateRefreshToken user = %v, want %v", first.UserID, user.ID)
+	}
+
+	second, err := c.RotateRefreshToken("first", CreateRefreshTokenParams{
+		Token:     "second",
+		UserID:    user.ID,
+		ExpiresAt: expiresAt.Add(time.Hour),
+		FamilyID:  first.FamilyID,
+	})
+	if err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	if second.FamilyID != first.FamilyID || second.RevokedAt != nil {
+		t.Errorf("rotated token = %+v", second)
+	}
+	if !second.ExpiresAt.Equal(first.ExpiresAt) {
+		t.Errorf("rotated token expires at %v, want the family's %v", second.ExpiresAt, first.ExpiresAt)
+	}
+	old, err := c.GetRefreshToken("first")
+	if err != nil {
+		t.Fatalf("GetRefreshToken: %v", err)
+	}
+	if old.RevokedAt == nil || !old.Rotated {
+		t.Errorf("rotation didn't revoke the old token: %+v", old)
+	}
+
+	_, err = c.RotateRefreshToken("first", CreateRefreshTokenParams{
+		Token:     "third",
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+		FamilyID:  first.FamilyID,
+	})
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("rotating a revoked token: err = %v, want ErrRefreshTokenReused", err)
+	}
+
+	err = c.RevokeRefreshTokenFamily(first.FamilyID)
+	if err != nil {
+		t.Fatalf("RevokeRefreshTokenFamily: %v", err)
+	}
+	second, err = c.GetRefreshToken("second")
+	if err != nil {
+		t.Fatalf("GetRefreshToken: %v", err)
+	}
+	if second.RevokedAt == nil {
+		t.Error("RevokeRefreshTokenFamily didn't revoke the newest token")
+	}
+	if second.Rotated {
+		t.Error("RevokeRefreshTokenFamily marked the newest token as rotated")
+	}
+
+	_, err = c.GetRefreshToken("unknown")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetRefreshToken for an unknown token: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testSessions(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	other := createTestUser(t, c)
+	expiresAt := time.Now().Add(time.Hour)
+	laptop, err := c.CreateRefreshToken(CreateRefreshTokenParams{
+		Token:     "laptop",
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+		UserAgent: "Firefox",
+		IP:        "192.0.2.1",
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	_, err = c.RotateRefreshToken("laptop", CreateRefreshTokenParams{
+		Token:     "laptop 2",
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+		FamilyID:  laptop.FamilyID,
+		UserAgent: "Firefox",
+		IP:        "192.0.2.2",
+	})
+	if err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	phone, err := c.CreateRefreshToken(CreateRefreshTokenParams{
+		Token:     "phone",
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+
+	sessions, err := c.GetSessions(user.ID)
+	if err != nil {
+		t.Fatalf("GetSessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("GetSessions = %v, want two sessions", sessions)
+	}
+	for _, session := range sessions {
+		if session.ID == laptop.FamilyID && session.IP != "192.0.2.2" {
+			t.Errorf("session IP = %q, want the newest token's", session.IP)
+		}
+	}
+
+	err = c.RevokeSession(other.ID, phone.FamilyID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("RevokeSession for another user's session: err = %v, want ErrNotFound", err)
+	}
+	err = c.RevokeSession(user.ID, phone.FamilyID)
+	if err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	err = c.RevokeAllSessions(user.ID)
+	if err != nil {
+		t.Fatalf("RevokeAllSessions: %v", err)
+	}
+	sessions, err = c.GetSessions(user.ID)
+	if err != nil {
+		t.Fatalf("GetSessions: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("GetSessions after revoking everything = %v, want none", sessions)
+	}
+}
+
+func testAPIKeys(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	other := createTestUser(t, c)
+	key, err := c.CreateAPIKey(CreateAPIKeyParams{
+		UserID: user.ID,
+		Name:   "ci",
+		Key:    "tubely_secret",
+		Scopes: []string{"videos:read", "uploads:write"},
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	got, err := c.GetAPIKeyByKey("tubely_secret")
+	if err != nil {
+		t.Fatalf("GetAPIKeyByKey: %v", err)
+	}
+	if got.ID != key.ID || !slices.Equal(got.Scopes, key.Scopes) {
+		t.Errorf("GetAPIKeyByKey = %+v, want %+v", got, key)
+	}
+	if got.LastUsedAt != nil {
+		t.Error("unused key has last_used_at")
+	}
+	err = c.TouchAPIKey(key.ID)
+	if err != nil {
+		t.Fatalf("TouchAPIKey: %v", err)
+	}
+	keys, err := c.GetAPIKeys(user.ID)
+	if err != nil {
+		t.Fatalf("GetAPIKeys: %v", err)
+	}
+	if len(keys) != 1 || keys[0].LastUsedAt == nil {
+		t.Errorf("GetAPIKeys after TouchAPIKey = %+v", keys)
+	}
+
+	err = c.RevokeAPIKey(other.ID, key.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("RevokeAPIKey for another user's key: err = %v, want ErrNotFound", err)
+	}
+	err = c.RevokeAPIKey(user.ID, key.ID)
+	if err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	_, err = c.GetAPIKeyByKey("tubely_secret")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAPIKeyByKey for a revoked key: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testDeniedAccessTokens(t *testing.T, c Client) {
+	user := createTestUser(t, c)
+	now := time.Now()
+	for _, params := range []DenyAccessTokenParams{
+		{ID: "expired", UserID: user.ID, ExpiresAt: now.Add(-time.Minute)},
+		{ID: "live", UserID: user.ID, ExpiresAt: now.Add(time.Minute)},
+		{ID: "live", UserID: user.ID, ExpiresAt: now.Add(time.Minute)},
+	} {
+		err := c.DenyAccessToken(params)
+		if err != nil {
+			t.Fatalf("DenyAccessToken(%s): %v", params.ID, err)
+		}
+	}
+
+	n, err := c.DeleteExpiredDeniedAccessTokens(now)
+	if err != nil {
+		t.Fatalf("DeleteExpiredDeniedAccessTokens: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("DeleteExpiredDeniedAccessTokens dropped %d, want 1", n)
+	}
+	for id, want := range map[string]bool{"expired": false, "live": true, "unknown": false} {
+		denied, err := c.IsAccessTokenDenied(id)
+		if err != nil {
+			t.Fatalf("IsAccessTokenDenied: %v", err)
+		}
+		if denied != want {
+			t.Errorf("IsAccessTokenDenied(%s) = %v, want %v", id, denied, want)
+		}
+	}
+}
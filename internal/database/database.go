@@ -2,88 +2,269 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/google/uuid"
 )
 
-type Client struct {
-	db *sql.DB
+const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
+)
+
+// ErrNotFound is returned by getters when the requested row doesn't exist.
+// More specific errors wrap it, so check for it with errors.Is.
+var ErrNotFound = errors.New("not found")
+
+type UserRepository interface {
+	GetUsers() ([]User, error)
+	GetUserByEmail(email string) (User, error)
+	CreateUser(params CreateUserParams) (*User, error)
+	GetUser(id uuid.UUID) (*User, error)
+	UpdateUserProfile(id uuid.UUID, params UpdateUserProfileParams) (*User, error)
+	SetUserAdmin(id uuid.UUID, isAdmin bool) error
+	DeleteUser(id uuid.UUID) error
+}
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(params CreateRefreshTokenParams) (RefreshToken, error)
+	RotateRefreshToken(oldToken string, params CreateRefreshTokenParams) (RefreshToken, error)
+	RevokeRefreshToken(token string) error
+	RevokeRefreshTokenFamily(familyID uuid.UUID) error
+	GetRefreshToken(token string) (RefreshToken, error)
+	DeleteRefreshToken(token string) error
+	GetSessions(userID uuid.UUID) ([]Session, error)
+	RevokeSession(userID, sessionID uuid.UUID) error
+	RevokeAllSessions(userID uuid.UUID) error
+}
+
+type DeniedAccessTokenRepository interface {
+	DenyAccessToken(params DenyAccessTokenParams) error
+	IsAccessTokenDenied(id string) (bool, error)
+	DeleteExpiredDeniedAccessTokens(cutoff time.Time) (int64, error)
+}
+
+type APIKeyRepository interface {
+	CreateAPIKey(params CreateAPIKeyParams) (APIKey, error)
+	GetAPIKeys(userID uuid.UUID) ([]APIKey, error)
+	GetAPIKeyByKey(key string) (APIKey, error)
+	TouchAPIKey(id uuid.UUID) error
+	RevokeAPIKey(userID, id uuid.UUID) error
+}
+
+type VideoRepository interface {
+	GetVideos(userID uuid.UUID) ([]Video, error)
+	ListVideos(params ListVideosParams) (VideoPage, error)
+	SearchVideos(params SearchVideosParams) (VideoSearchPage, error)
+	CreateVideo(params CreateVideoParams) (Video, error)
+	GetVideo(id uuid.UUID) (Video, error)
+	UpdateVideo(video Video) (Video, error)
+	UpdateVideoIfUnmodified(video Video) (Video, error)
+	DeleteVideo(id uuid.UUID) error
+	TrashVideo(id uuid.UUID) error
+	GetTrashedVideo(id uuid.UUID) (Video, error)
+	GetTrashedVideos(userID uuid.UUID) ([]Video, error)
+	GetVideosTrashedBefore(cutoff time.Time) ([]Video, error)
+	RestoreVideo(id uuid.UUID) (Video, error)
+	GetStorageUsage(userID uuid.UUID) (StorageUsage, error)
+}
+
+type PlaylistRepository interface {
+	CreatePlaylist(params CreatePlaylistParams) (Playlist, error)
+	GetPlaylist(id uuid.UUID) (Playlist, error)
+	GetPlaylists(userID uuid.UUID) ([]Playlist, error)
+	UpdatePlaylist(playlist Playlist) (Playlist, error)
+	DeletePlaylist(id uuid.UUID) error
+	GetPlaylistEntries(playlistID uuid.UUID) ([]PlaylistEntry, error)
+	AddPlaylistEntry(playlistID, videoID uuid.UUID, position int) error
+	RemovePlaylistEntry(playlistID, videoID uuid.UUID) error
+	MovePlaylistEntry(playlistID, videoID uuid.UUID, position int) error
+}
+
+type TagRepository interface {
+	GetVideoTags(videoID uuid.UUID) ([]string, error)
+	SetVideoTags(video Video, tags []string) ([]string, error)
+	RemoveVideoTag(video Video, tag string) error
+	GetTags(userID uuid.UUID) ([]TagCount, error)
+}
+
+// Client is the storage backend used by the API. SQLiteClient and
+// PostgresClient both implement it.
+type Client interface {
+	UserRepository
+	RefreshTokenRepository
+	DeniedAccessTokenRepository
+	APIKeyRepository
+	VideoRepository
+	TagRepository
+	PlaylistRepository
+	Migrator
+	Reset() error
+	Close() error
 }
 
+// NewClient opens the SQLite database at pathToDB.
 func NewClient(pathToDB string) (Client, error) {
-	db, err := sql.Open("sqlite3", pathToDB)
+	return Open(DriverSQLite, pathToDB)
+}
+
+// Open connects to the database for the given driver and applies any
+// pending migrations.
+func Open(driver, dsn string) (Client, error) {
+	c, err := Connect(driver, dsn)
 	if err != nil {
-		return Client{}, err
+		return nil, err
 	}
-	c := Client{db}
-	err = c.autoMigrate()
+	err = c.MigrateUp()
 	if err != nil {
-		return Client{}, err
+		c.Close()
+		return nil, err
 	}
 	return c, nil
-
 }
 
-func (c *Client) autoMigrate() error {
-	userTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id TEXT PRIMARY KEY,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		password TEXT NOT NULL,
-		email TEXT UNIQUE NOT NULL
-	);
-	`
-	_, err := c.db.Exec(userTable)
-	if err != nil {
-		return err
-	}
-	refreshTokenTable := `
-	CREATE TABLE IF NOT EXISTS refresh_tokens (
-		token TEXT PRIMARY KEY,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		revoked_at TIMESTAMP,
-		user_id TEXT NOT NULL,
-		expires_at TIMESTAMP NOT NULL,
-		FOREIGN KEY(user_id) REFERENCES users(id)
-	);
-	`
-	_, err = c.db.Exec(refreshTokenTable)
-	if err != nil {
-		return err
+// Connect connects to the database for the given driver without running
+// migrations.
+func Connect(driver, dsn string) (Client, error) {
+	switch driver {
+	case DriverSQLite:
+		return NewSQLiteClient(dsn)
+	case DriverPostgres:
+		return NewPostgresClient(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
 	}
+}
 
-	videoTable := `
-	CREATE TABLE IF NOT EXISTS videos (
-		id TEXT PRIMARY KEY,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		title TEXT NOT NULL,
-		description TEXT,
-		thumbnail_url TEXT,
-		video_url TEXT TEXT,
-		user_id INTEGER,
-		FOREIGN KEY(user_id) REFERENCES users(id)
-	);
-	`
-	_, err = c.db.Exec(videoTable)
-	if err != nil {
-		return err
-	}
-	return nil
+// sqlStore holds the queries shared by every SQL backend. Queries are
+// written with ? placeholders and rewritten for drivers that need another
+// style.
+type sqlStore struct {
+	db     *rebindDB
+	driver string
 }
 
-func (c Client) Reset() error {
+func (c sqlStore) Reset() error {
+	if _, err := c.db.Exec("DELETE FROM videos"); err != nil {
+		return fmt.Errorf("failed to reset table videos: %w", err)
+	}
 	if _, err := c.db.Exec("DELETE FROM refresh_tokens"); err != nil {
 		return fmt.Errorf("failed to reset table refresh_tokens: %w", err)
 	}
+	if _, err := c.db.Exec("DELETE FROM api_keys"); err != nil {
+		return fmt.Errorf("failed to reset table api_keys: %w", err)
+	}
+	if _, err := c.db.Exec("DELETE FROM denied_access_tokens"); err != nil {
+		return fmt.Errorf("failed to reset table denied_access_tokens: %w", err)
+	}
 	if _, err := c.db.Exec("DELETE FROM users"); err != nil {
 		return fmt.Errorf("failed to reset table users: %w", err)
 	}
-	if _, err := c.db.Exec("DELETE FROM videos"); err != nil {
-		return fmt.Errorf("failed to reset table videos: %w", err)
-	}
 	return nil
 }
+
+func (c sqlStore) Close() error {
+	return c.db.Close()
+}
+
+// rebindDB wraps *sql.DB so that queries written with ? placeholders work
+// with drivers that expect numbered $1, $2, ... placeholders.
+type rebindDB struct {
+	*sql.DB
+	numbered bool
+}
+
+func (db *rebindDB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.DB.Exec(db.rebind(query), args...)
+}
+
+func (db *rebindDB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.DB.Query(db.rebind(query), args...)
+}
+
+func (db *rebindDB) QueryRow(query string, args ...any) *sql.Row {
+	return db.DB.QueryRow(db.rebind(query), args...)
+}
+
+func (db *rebindDB) rebind(query string) string {
+	if !db.numbered {
+		return query
+	}
+	return numberPlaceholders(query)
+}
+
+// numberPlaceholders rewrites ? placeholders as $1, $2, ... It leaves
+// string literals, quoted identifiers and comments alone, and turns ?? into
+// a literal ? for Postgres operators such as jsonb's ?, ?| and ?&.
+func numberPlaceholders(query string) string {
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			// Quotes are escaped by doubling them, which this handles as
+			// two adjacent quoted sections.
+			stop := len(query)
+			if j := strings.IndexByte(query[i+1:], c); j >= 0 {
+				stop = i + 1 + j + 1
+			}
+			b.WriteString(query[i:stop])
+			i = stop - 1
+		case strings.HasPrefix(query[i:], "--"):
+			stop := len(query)
+			if j := strings.IndexByte(query[i:], '\n'); j >= 0 {
+				stop = i + j
+			}
+			b.WriteString(query[i:stop])
+			i = stop - 1
+		case strings.HasPrefix(query[i:], "/*"):
+			stop := len(query)
+			if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+				stop = i + 2 + j + 2
+			}
+			b.WriteString(query[i:stop])
+			i = stop - 1
+		case c == '?' && strings.HasPrefix(query[i:], "??"):
+			b.WriteByte('?')
+			i++
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func (db *rebindDB) Begin() (*rebindTx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &rebindTx{Tx: tx, db: db}, nil
+}
+
+// rebindTx is the transaction counterpart of rebindDB.
+type rebindTx struct {
+	*sql.Tx
+	db *rebindDB
+}
+
+func (tx *rebindTx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.Tx.Exec(tx.db.rebind(query), args...)
+}
+
+func (tx *rebindTx) Query(query string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.db.rebind(query), args...)
+}
+
+func (tx *rebindTx) QueryRow(query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRow(tx.db.rebind(query), args...)
+}
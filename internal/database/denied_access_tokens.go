@@ -0,0 +1,52 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DenyAccessTokenParams struct {
+	// ID is the token's jti claim.
+	ID        string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+// DenyAccessToken puts an access token on the deny-list until it expires.
+// Denying a token twice is not an error.
+func (c sqlStore) DenyAccessToken(params DenyAccessTokenParams) error {
+	query := `
+	INSERT INTO denied_access_tokens (id, created_at, user_id, expires_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT (id) DO NOTHING
+	`
+	_, err := c.db.Exec(query, params.ID, timestamp(), params.UserID, params.ExpiresAt.UTC())
+	return err
+}
+
+// IsAccessTokenDenied reports whether the access token with the given jti
+// has been revoked.
+func (c sqlStore) IsAccessTokenDenied(id string) (bool, error) {
+	var found int
+	err := c.db.QueryRow("SELECT 1 FROM denied_access_tokens WHERE id = ?", id).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteExpiredDeniedAccessTokens drops deny-list entries for tokens that
+// expired before cutoff, and returns how many it dropped.
+func (c sqlStore) DeleteExpiredDeniedAccessTokens(cutoff time.Time) (int64, error) {
+	result, err := c.db.Exec("DELETE FROM denied_access_tokens WHERE expires_at < ?", cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
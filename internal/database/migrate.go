@@ -0,0 +1,257 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations
+var migrationFS embed.FS
+
+// Migrator applies the versioned schema migrations embedded in the binary.
+// Migrations live in migrations/<driver>/NNNN_name.{up,down}.sql.
+type Migrator interface {
+	MigrateUp() error
+	MigrateDown(steps int) error
+	SchemaVersion() (int, error)
+}
+
+// upHooks run after a migration's up script, in the same transaction, for
+// data changes that can't be written in SQL on every driver.
+var upHooks = map[int]func(c sqlStore, tx *sql.Tx) error{
+	14: hashExistingRefreshTokens,
+}
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+func loadMigrations(driver string) ([]migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(fileName, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("unexpected migration file %s", fileName)
+		}
+		versionString, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", fileName, err)
+		}
+
+		dat, err := fs.ReadFile(migrationFS, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(dat)
+		} else {
+			m.down = string(dat)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
+
+func (c sqlStore) ensureMigrationsTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := c.db.Exec(query)
+	return err
+}
+
+func (c sqlStore) SchemaVersion() (int, error) {
+	err := c.ensureMigrationsTable()
+	if err != nil {
+		return 0, err
+	}
+	var version int
+	err = c.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// MigrateUp applies every migration newer than the current schema version.
+func (c sqlStore) MigrateUp() error {
+	migrations, err := loadMigrations(c.driver)
+	if err != nil {
+		return err
+	}
+	current, err := c.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		err := c.applyMigration(m.version, m.name, m.up, true)
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s failed: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// MigrateDown rolls back the given number of applied migrations, newest first.
+func (c sqlStore) MigrateDown(steps int) error {
+	migrations, err := loadMigrations(c.driver)
+	if err != nil {
+		return err
+	}
+	current, err := c.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if m.version > current {
+			continue
+		}
+		if m.down == "" {
+			return fmt.Errorf("migration %04d_%s can't be rolled back", m.version, m.name)
+		}
+		err := c.applyMigration(m.version, m.name, m.down, false)
+		if err != nil {
+			return fmt.Errorf("rollback of %04d_%s failed: %w", m.version, m.name, err)
+		}
+		steps--
+	}
+	return nil
+}
+
+func (c sqlStore) applyMigration(version int, name, script string, up bool) error {
+	ctx := context.Background()
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// SQLite migrations rebuild tables, which would trip (or cascade through)
+	// foreign keys mid-way. Turn enforcement off for this connection and
+	// check for new violations before committing instead. The pragma is a
+	// no-op inside a transaction, so it has to be set first.
+	if c.driver == DriverSQLite {
+		_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+		if err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Databases from before foreign keys were enforced can already hold
+	// rows whose user was deleted, until 0003 clears them out. Only fail on
+	// violations the migration adds.
+	var violationsBefore map[string]int
+	if c.driver == DriverSQLite {
+		violationsBefore, err = foreignKeyViolations(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(script)
+	if err != nil {
+		return err
+	}
+	if hook := upHooks[version]; up && hook != nil {
+		err = hook(c, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.driver == DriverSQLite {
+		violationsAfter, err := foreignKeyViolations(tx)
+		if err != nil {
+			return err
+		}
+		for table, n := range violationsAfter {
+			if n > violationsBefore[table] {
+				return fmt.Errorf("migration leaves foreign key violations in %s", table)
+			}
+		}
+	}
+
+	if up {
+		_, err = tx.Exec(c.db.rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)"), version, name)
+	} else {
+		_, err = tx.Exec(c.db.rebind("DELETE FROM schema_migrations WHERE version = ?"), version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// foreignKeyViolations counts the rows in each SQLite table whose foreign
+// keys point at nothing.
+func foreignKeyViolations(tx *sql.Tx) (map[string]int, error) {
+	rows, err := tx.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	violations := map[string]int{}
+	for rows.Next() {
+		var table, parent string
+		var rowID sql.NullInt64
+		var fkID int
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return nil, err
+		}
+		violations[table]++
+	}
+	return violations, rows.Err()
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// baselineSchema is what the server created before migrations existed.
+// Foreign keys weren't enforced then, so deleting a user left their videos
+// and refresh tokens behind.
+const baselineSchema = `
+CREATE TABLE users (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	password TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL
+);
+CREATE TABLE refresh_tokens (
+	token TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	revoked_at TIMESTAMP,
+	user_id TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+CREATE TABLE videos (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	title TEXT NOT NULL,
+	description TEXT,
+	thumbnail_url TEXT,
+	video_url TEXT TEXT,
+	user_id INTEGER,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+INSERT INTO users (id, password, email) VALUES ('11111111-1111-1111-1111-111111111111', 'hash', 'kept@example.com');
+INSERT INTO videos (id, title, user_id) VALUES ('22222222-2222-2222-2222-222222222222', 'kept', '11111111-1111-1111-1111-111111111111');
+INSERT INTO videos (id, title, user_id) VALUES ('33333333-3333-3333-3333-333333333333', 'deleted owner', '44444444-4444-4444-4444-444444444444');
+INSERT INTO videos (id, title, user_id) VALUES ('55555555-5555-5555-5555-555555555555', 'no owner', NULL);
+INSERT INTO refresh_tokens (token, user_id, expires_at) VALUES ('dangling', '44444444-4444-4444-4444-444444444444', CURRENT_TIMESTAMP);
+`
+
+func TestSQLiteUpgradeBaselineWithDanglingRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tubely.db")
+	baseline, err := sql.Open(DriverSQLite, path)
+	if err != nil {
+		t.Fatalf("Couldn't open baseline database: %v", err)
+	}
+	_, err = baseline.Exec(baselineSchema)
+	baseline.Close()
+	if err != nil {
+		t.Fatalf("Couldn't create baseline database: %v", err)
+	}
+
+	c, err := NewSQLiteClient(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteClient: %v", err)
+	}
+	defer c.Close()
+	err = c.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	db := c.db
+
+	var orphaned []string
+	rows, err := db.Query("SELECT title FROM videos_orphaned ORDER BY title")
+	if err != nil {
+		t.Fatalf("Couldn't read videos_orphaned: %v", err)
+	}
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			t.Fatal(err)
+		}
+		orphaned = append(orphaned, title)
+	}
+	rows.Close()
+	if len(orphaned) != 2 || orphaned[0] != "deleted owner" || orphaned[1] != "no owner" {
+		t.Errorf("videos_orphaned = %v, want [deleted owner no owner]", orphaned)
+	}
+
+	var kept string
+	err = db.QueryRow("SELECT title FROM videos").Scan(&kept)
+	if err != nil || kept != "kept" {
+		t.Errorf("videos = %q, %v, want just kept", kept, err)
+	}
+	_, err = c.GetRefreshToken("dangling")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetRefreshToken for a deleted user's token: err = %v, want ErrNotFound", err)
+	}
+}
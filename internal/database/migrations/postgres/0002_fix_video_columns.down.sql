@@ -0,0 +1,7 @@
+ALTER TABLE videos
+	ALTER COLUMN description DROP DEFAULT,
+	ALTER COLUMN description DROP NOT NULL,
+	ALTER COLUMN user_id DROP NOT NULL;
+
+INSERT INTO videos SELECT * FROM videos_orphaned;
+DROP TABLE videos_orphaned;
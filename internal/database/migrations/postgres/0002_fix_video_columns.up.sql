@@ -0,0 +1,10 @@
+-- Videos without an owner can't be kept once user_id is NOT NULL. Move them
+-- aside rather than losing them, so they can be looked at and reassigned.
+CREATE TABLE videos_orphaned AS SELECT * FROM videos WHERE user_id IS NULL;
+DELETE FROM videos WHERE user_id IS NULL;
+UPDATE videos SET description = '' WHERE description IS NULL;
+
+ALTER TABLE videos
+	ALTER COLUMN description SET DEFAULT '',
+	ALTER COLUMN description SET NOT NULL,
+	ALTER COLUMN user_id SET NOT NULL;
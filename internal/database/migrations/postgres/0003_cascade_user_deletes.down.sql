@@ -0,0 +1,7 @@
+ALTER TABLE refresh_tokens
+	DROP CONSTRAINT IF EXISTS refresh_tokens_user_id_fkey,
+	ADD CONSTRAINT refresh_tokens_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE videos
+	DROP CONSTRAINT IF EXISTS videos_user_id_fkey,
+	ADD CONSTRAINT videos_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id);
@@ -0,0 +1,13 @@
+DELETE FROM refresh_tokens WHERE user_id NOT IN (SELECT id FROM users);
+-- Videos whose owner was deleted before deletes cascaded. Like the ownerless
+-- ones in 0002, move them aside instead of dropping them with their files.
+INSERT INTO videos_orphaned SELECT * FROM videos WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM videos WHERE user_id NOT IN (SELECT id FROM users);
+
+ALTER TABLE refresh_tokens
+	DROP CONSTRAINT IF EXISTS refresh_tokens_user_id_fkey,
+	ADD CONSTRAINT refresh_tokens_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE videos
+	DROP CONSTRAINT IF EXISTS videos_user_id_fkey,
+	ADD CONSTRAINT videos_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,5 @@
+ALTER TABLE videos ADD COLUMN orientation TEXT;
+
+CREATE INDEX idx_videos_user_created ON videos (user_id, created_at, id);
+CREATE INDEX idx_videos_user_updated ON videos (user_id, updated_at, id);
+CREATE INDEX idx_videos_user_title ON videos (user_id, title, id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_videos_search;
+ALTER TABLE videos DROP COLUMN search;
@@ -0,0 +1,6 @@
+ALTER TABLE videos ADD COLUMN search tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('english', title), 'A') ||
+	setweight(to_tsvector('english', description), 'B')
+) STORED;
+
+CREATE INDEX idx_videos_search ON videos USING GIN (search);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_videos_visibility_created;
+ALTER TABLE videos DROP COLUMN visibility;
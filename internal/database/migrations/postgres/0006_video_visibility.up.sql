@@ -0,0 +1,7 @@
+ALTER TABLE videos ADD COLUMN visibility TEXT NOT NULL DEFAULT 'private'
+	CHECK (visibility IN ('private', 'unlisted', 'public'));
+
+-- Existing videos could be fetched by anyone with the ID, so keep them that way.
+UPDATE videos SET visibility = 'unlisted';
+
+CREATE INDEX idx_videos_visibility_created ON videos (visibility, created_at, id);
@@ -0,0 +1,15 @@
+CREATE TABLE tags (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	UNIQUE (user_id, name)
+);
+
+CREATE TABLE video_tags (
+	video_id TEXT NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+	tag_id TEXT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	PRIMARY KEY (video_id, tag_id)
+);
+
+CREATE INDEX idx_video_tags_tag ON video_tags (tag_id, video_id);
@@ -0,0 +1,26 @@
+CREATE TABLE playlists (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	title TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	visibility TEXT NOT NULL DEFAULT 'private'
+		CHECK (visibility IN ('private', 'unlisted', 'public')),
+	user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_playlists_user ON playlists (user_id, created_at);
+
+-- Positions are renumbered 0..n-1 whenever entries are added, removed or
+-- moved. Deleting a video can leave a gap until the next change, so only
+-- their order is meaningful.
+CREATE TABLE playlist_entries (
+	playlist_id TEXT NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+	video_id TEXT NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (playlist_id, video_id)
+);
+
+CREATE INDEX idx_playlist_entries_position ON playlist_entries (playlist_id, position);
+CREATE INDEX idx_playlist_entries_video ON playlist_entries (video_id);
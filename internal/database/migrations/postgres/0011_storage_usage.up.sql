@@ -0,0 +1,4 @@
+-- Stored file sizes in bytes, used for storage quotas. Files uploaded before
+-- this migration count as zero until they are replaced.
+ALTER TABLE videos ADD COLUMN video_size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE videos ADD COLUMN thumbnail_size BIGINT NOT NULL DEFAULT 0;
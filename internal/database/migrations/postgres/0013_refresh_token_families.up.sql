@@ -0,0 +1,8 @@
+-- A family is the chain of refresh tokens issued by rotating one login's
+-- token. Reusing a rotated token revokes the whole family.
+ALTER TABLE refresh_tokens ADD COLUMN family_id TEXT NOT NULL DEFAULT '';
+
+-- Existing tokens each start their own family.
+UPDATE refresh_tokens SET family_id = gen_random_uuid()::text;
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
@@ -0,0 +1,3 @@
+-- The hashes can't be turned back into tokens, so everyone signs in again.
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
@@ -0,0 +1,9 @@
+-- Each refresh token family is a session the user can see and revoke.
+-- These record where it was last used from and when.
+ALTER TABLE refresh_tokens ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN last_used_at TIMESTAMP;
+
+UPDATE refresh_tokens SET last_used_at = created_at;
+
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens (user_id);
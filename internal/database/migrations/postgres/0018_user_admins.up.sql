@@ -0,0 +1,2 @@
+-- Admins get the admin scope when they log in. Grant it with `tubely admin grant`.
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
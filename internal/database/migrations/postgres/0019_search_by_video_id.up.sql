@@ -0,0 +1,3 @@
+-- Only SQLite's search index needed changing (see sqlite3/0019). Postgres
+-- searches a generated column on videos itself.
+SELECT 1;
@@ -0,0 +1,3 @@
+-- Set when a token is revoked because it was exchanged for a new one, as
+-- opposed to a logout. Only presenting a rotated token counts as reuse.
+ALTER TABLE refresh_tokens ADD COLUMN rotated BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,24 @@
+-- SQLite can't change a column's type in place, so rebuild the table.
+CREATE TABLE videos_new (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	title TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	thumbnail_url TEXT,
+	video_url TEXT,
+	user_id TEXT NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+-- Videos without an owner can't be kept once user_id is NOT NULL. Move them
+-- aside rather than losing them, so they can be looked at and reassigned.
+CREATE TABLE videos_orphaned AS SELECT * FROM videos WHERE user_id IS NULL;
+
+INSERT INTO videos_new (id, created_at, updated_at, title, description, thumbnail_url, video_url, user_id)
+SELECT id, created_at, updated_at, title, COALESCE(description, ''), thumbnail_url, video_url, CAST(user_id AS TEXT)
+FROM videos
+WHERE user_id IS NOT NULL;
+
+DROP TABLE videos;
+ALTER TABLE videos_new RENAME TO videos;
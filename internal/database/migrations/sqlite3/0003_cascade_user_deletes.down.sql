@@ -0,0 +1,29 @@
+CREATE TABLE refresh_tokens_old (
+	token TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	revoked_at TIMESTAMP,
+	user_id TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+INSERT INTO refresh_tokens_old SELECT token, created_at, updated_at, revoked_at, user_id, expires_at FROM refresh_tokens;
+DROP TABLE refresh_tokens;
+ALTER TABLE refresh_tokens_old RENAME TO refresh_tokens;
+
+CREATE TABLE videos_old (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	title TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	thumbnail_url TEXT,
+	video_url TEXT,
+	user_id TEXT NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+INSERT INTO videos_old SELECT id, created_at, updated_at, title, description, thumbnail_url, video_url, user_id FROM videos;
+DROP TABLE videos;
+ALTER TABLE videos_old RENAME TO videos;
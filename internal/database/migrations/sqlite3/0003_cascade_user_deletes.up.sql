@@ -0,0 +1,41 @@
+CREATE TABLE refresh_tokens_new (
+	token TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	revoked_at TIMESTAMP,
+	user_id TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO refresh_tokens_new (token, created_at, updated_at, revoked_at, user_id, expires_at)
+SELECT token, created_at, updated_at, revoked_at, user_id, expires_at
+FROM refresh_tokens
+WHERE user_id IN (SELECT id FROM users);
+
+DROP TABLE refresh_tokens;
+ALTER TABLE refresh_tokens_new RENAME TO refresh_tokens;
+
+-- Videos whose owner was deleted before deletes cascaded. Like the ownerless
+-- ones in 0002, move them aside instead of dropping them with their files.
+INSERT INTO videos_orphaned SELECT * FROM videos WHERE user_id NOT IN (SELECT id FROM users);
+
+CREATE TABLE videos_new (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	title TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	thumbnail_url TEXT,
+	video_url TEXT,
+	user_id TEXT NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO videos_new (id, created_at, updated_at, title, description, thumbnail_url, video_url, user_id)
+SELECT id, created_at, updated_at, title, description, thumbnail_url, video_url, user_id
+FROM videos
+WHERE user_id IN (SELECT id FROM users);
+
+DROP TABLE videos;
+ALTER TABLE videos_new RENAME TO videos;
@@ -0,0 +1,11 @@
+ALTER TABLE videos ADD COLUMN orientation TEXT;
+
+-- Timestamps are now written by the application in the driver's format.
+-- Bring rows written with CURRENT_TIMESTAMP in line so comparisons against
+-- pagination cursors behave.
+UPDATE videos SET created_at = created_at || '+00:00' WHERE length(created_at) = 19;
+UPDATE videos SET updated_at = updated_at || '+00:00' WHERE length(updated_at) = 19;
+
+CREATE INDEX idx_videos_user_created ON videos (user_id, created_at, id);
+CREATE INDEX idx_videos_user_updated ON videos (user_id, updated_at, id);
+CREATE INDEX idx_videos_user_title ON videos (user_id, title, id);
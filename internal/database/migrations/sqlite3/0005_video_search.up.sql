@@ -0,0 +1,22 @@
+-- Requires SQLite built with FTS5 (go build -tags sqlite_fts5).
+CREATE VIRTUAL TABLE videos_fts USING fts5 (
+	title,
+	description,
+	content = 'videos',
+	content_rowid = 'rowid'
+);
+
+INSERT INTO videos_fts (videos_fts) VALUES ('rebuild');
+
+CREATE TRIGGER videos_fts_insert AFTER INSERT ON videos BEGIN
+	INSERT INTO videos_fts (rowid, title, description) VALUES (new.rowid, new.title, new.description);
+END;
+
+CREATE TRIGGER videos_fts_delete AFTER DELETE ON videos BEGIN
+	INSERT INTO videos_fts (videos_fts, rowid, title, description) VALUES ('delete', old.rowid, old.title, old.description);
+END;
+
+CREATE TRIGGER videos_fts_update AFTER UPDATE OF title, description ON videos BEGIN
+	INSERT INTO videos_fts (videos_fts, rowid, title, description) VALUES ('delete', old.rowid, old.title, old.description);
+	INSERT INTO videos_fts (rowid, title, description) VALUES (new.rowid, new.title, new.description);
+END;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_videos_deleted_at;
+-- Without the column trashed videos would reappear, so they're dropped.
+-- Their storage objects are not cleaned up.
+DELETE FROM videos WHERE deleted_at IS NOT NULL;
+ALTER TABLE videos DROP COLUMN deleted_at;
@@ -0,0 +1,4 @@
+-- Deleted videos are kept in the trash until the retention job purges them.
+ALTER TABLE videos ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_videos_deleted_at ON videos (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE videos DROP COLUMN thumbnail_size;
+ALTER TABLE videos DROP COLUMN video_size;
@@ -0,0 +1,2 @@
+-- Plans are defined in the server's configuration; the column only names one.
+ALTER TABLE users ADD COLUMN plan TEXT NOT NULL DEFAULT 'free';
@@ -0,0 +1,15 @@
+-- A family is the chain of refresh tokens issued by rotating one login's
+-- token. Reusing a rotated token revokes the whole family.
+ALTER TABLE refresh_tokens ADD COLUMN family_id TEXT NOT NULL DEFAULT '';
+
+-- Existing tokens each start their own family. SQLite has no UUID function,
+-- so build a random version 4 UUID by hand.
+UPDATE refresh_tokens SET family_id = lower(
+	hex(randomblob(4)) || '-' ||
+	hex(randomblob(2)) || '-' ||
+	'4' || substr(hex(randomblob(2)), 2) || '-' ||
+	substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' ||
+	hex(randomblob(6))
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
@@ -0,0 +1,4 @@
+-- Refresh tokens are stored as SHA-256 hashes so a copy of the database
+-- can't be used to sign in. The existing rows are hashed by the Go hook
+-- registered for this version in migrate.go.
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_refresh_tokens_user;
+ALTER TABLE refresh_tokens DROP COLUMN last_used_at;
+ALTER TABLE refresh_tokens DROP COLUMN ip;
+ALTER TABLE refresh_tokens DROP COLUMN user_agent;
@@ -0,0 +1,17 @@
+-- API keys let scripts act as a user without a password login. Only a
+-- SHA-256 hash of each key is stored; prefix is kept so users can tell
+-- their keys apart. Scopes are space-separated.
+CREATE TABLE api_keys (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	user_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	prefix TEXT NOT NULL,
+	scopes TEXT NOT NULL DEFAULT '',
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_api_keys_user ON api_keys (user_id, created_at);
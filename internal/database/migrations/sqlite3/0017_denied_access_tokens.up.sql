@@ -0,0 +1,11 @@
+-- Access tokens revoked before they expire, by jti. Rows can be deleted
+-- once the token has expired anyway.
+CREATE TABLE denied_access_tokens (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	user_id TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_denied_access_tokens_expires_at ON denied_access_tokens (expires_at);
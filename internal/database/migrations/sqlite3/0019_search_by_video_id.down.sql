@@ -0,0 +1,27 @@
+-- Back to the 0005 index, which points at videos by rowid.
+DROP TRIGGER IF EXISTS videos_fts_update;
+DROP TRIGGER IF EXISTS videos_fts_delete;
+DROP TRIGGER IF EXISTS videos_fts_insert;
+DROP TABLE IF EXISTS videos_fts;
+
+CREATE VIRTUAL TABLE videos_fts USING fts5 (
+	title,
+	description,
+	content = 'videos',
+	content_rowid = 'rowid'
+);
+
+INSERT INTO videos_fts (videos_fts) VALUES ('rebuild');
+
+CREATE TRIGGER videos_fts_insert AFTER INSERT ON videos BEGIN
+	INSERT INTO videos_fts (rowid, title, description) VALUES (new.rowid, new.title, new.description);
+END;
+
+CREATE TRIGGER videos_fts_delete AFTER DELETE ON videos BEGIN
+	INSERT INTO videos_fts (videos_fts, rowid, title, description) VALUES ('delete', old.rowid, old.title, old.description);
+END;
+
+CREATE TRIGGER videos_fts_update AFTER UPDATE OF title, description ON videos BEGIN
+	INSERT INTO videos_fts (videos_fts, rowid, title, description) VALUES ('delete', old.rowid, old.title, old.description);
+	INSERT INTO videos_fts (rowid, title, description) VALUES (new.rowid, new.title, new.description);
+END;
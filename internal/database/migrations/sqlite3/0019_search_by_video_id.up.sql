@@ -0,0 +1,29 @@
+-- The FTS index used to point at videos by rowid, which isn't stable for a
+-- table with a TEXT primary key: VACUUM may renumber it. The index now keeps
+-- its own copy of the text keyed by the video's id. Finding a video's row to
+-- update it scans the index, which is fine at the size a user's library gets.
+DROP TRIGGER IF EXISTS videos_fts_update;
+DROP TRIGGER IF EXISTS videos_fts_delete;
+DROP TRIGGER IF EXISTS videos_fts_insert;
+DROP TABLE IF EXISTS videos_fts;
+
+CREATE VIRTUAL TABLE videos_fts USING fts5 (
+	title,
+	description,
+	video_id UNINDEXED
+);
+
+INSERT INTO videos_fts (title, description, video_id)
+SELECT title, description, id FROM videos;
+
+CREATE TRIGGER videos_fts_insert AFTER INSERT ON videos BEGIN
+	INSERT INTO videos_fts (title, description, video_id) VALUES (new.title, new.description, new.id);
+END;
+
+CREATE TRIGGER videos_fts_delete AFTER DELETE ON videos BEGIN
+	DELETE FROM videos_fts WHERE video_id = old.id;
+END;
+
+CREATE TRIGGER videos_fts_update AFTER UPDATE OF title, description ON videos BEGIN
+	UPDATE videos_fts SET title = new.title, description = new.description WHERE video_id = old.id;
+END;
@@ -0,0 +1,3 @@
+-- Size in bytes of the user's avatar, used for storage quotas. Avatars
+-- uploaded before this migration count as zero until they are replaced.
+ALTER TABLE users ADD COLUMN avatar_size INTEGER NOT NULL DEFAULT 0;
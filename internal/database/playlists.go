@@ -0,0 +1,322 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const MaxPlaylistEntries = 500
+
+var (
+	ErrAlreadyInPlaylist     = errors.New("video is already in the playlist")
+	ErrPlaylistFull          = errors.New("playlist is full")
+	ErrPlaylistEntryNotFound = fmt.Errorf("video is not in the playlist: %w", ErrNotFound)
+)
+
+// Playlists use the same visibility levels as videos.
+type Playlist struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	CreatePlaylistParams
+}
+
+type CreatePlaylistParams struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Visibility  string    `json:"visibility"`
+	UserID      uuid.UUID `json:"user_id"`
+}
+
+type PlaylistEntry struct {
+	Position int       `json:"position"`
+	AddedAt  time.Time `json:"added_at"`
+	Video    Video     `json:"video"`
+}
+
+func scanPlaylist(row rowScanner) (Playlist, error) {
+	var playlist Playlist
+	err := row.Scan(
+		&playlist.ID,
+		&playlist.CreatedAt,
+		&playlist.UpdatedAt,
+		&playlist.Title,
+		&playlist.Description,
+		&playlist.Visibility,
+		&playlist.UserID,
+	)
+	return playlist, err
+}
+
+func (c sqlStore) CreatePlaylist(params CreatePlaylistParams) (Playlist, error) {
+	id := uuid.New()
+	now := timestamp()
+	if params.Visibility == "" {
+		params.Visibility = VisibilityPrivate
+	}
+	query := `
+	INSERT INTO playlists (
+		id,
+		created_at,
+		updated_at,
+		title,
+		description,
+		visibility,
+		user_id
+	) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := c.db.Exec(query, id, now, now, params.Title, params.Description, params.Visibility, params.UserID)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	return c.GetPlaylist(id)
+}
+
+func (c sqlStore) GetPlaylist(id uuid.UUID) (Playlist, error) {
+	query := `
+	SELECT id, created_at, updated_at, title, description, visibility, user_id
+	FROM playlists
+	WHERE id = ?
+	`
+	playlist, err := scanPlaylist(c.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Playlist{}, ErrNotFound
+		}
+		return Playlist{}, err
+	}
+	return playlist, nil
+}
+
+func (c sqlStore) GetPlaylists(userID uuid.UUID) ([]Playlist, error) {
+	query := `
+	SELECT id, created_at, updated_at, title, description, visibility, user_id
+	FROM playlists
+	WHERE user_id = ?
+	ORDER BY created_at DESC
+	`
+	rows, err := c.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := []Playlist{}
+	for rows.Next() {
+		playlist, err := scanPlaylist(rows)
+		if err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, playlist)
+	}
+	return playlists, rows.Err()
+}
+
+func (c sqlStore) UpdatePlaylist(playlist Playlist) (Playlist, error) {
+	query := `
+	UPDATE playlists
+	SET
+		updated_at = ?,
+		title = ?,
+		description = ?,
+		visibility = ?
+	WHERE id = ?
+	`
+	_, err := c.db.Exec(query, timestamp(), playlist.Title, playlist.Description, playlist.Visibility, playlist.ID)
+	if err != nil {
+		return Playlist{}, err
+	}
+	return c.GetPlaylist(playlist.ID)
+}
+
+func (c sqlStore) DeletePlaylist(id uuid.UUID) error {
+	_, err := c.db.Exec("DELETE FROM playlists WHERE id = ?", id)
+	return err
+}
+
+// GetPlaylistEntries returns the playlist's entries in order, each joined
+// with its video.
+func (c sqlStore) GetPlaylistEntries(playlistID uuid.UUID) ([]PlaylistEntry, error) {
+	query := `
+	SELECT` + qualifiedVideoColumns("v") + `,
+		pe.position,
+		pe.added_at
+	FROM playlist_entries pe
+	JOIN videos v ON v.id = pe.video_id
+	WHERE pe.playlist_id = ? AND v.deleted_at IS NULL
+	ORDER BY pe.position
+	`
+	rows, err := c.db.Query(query, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []PlaylistEntry{}
+	for rows.Next() {
+		var entry PlaylistEntry
+		entry.Video, err = scanVideo(rows, &entry.Position, &entry.AddedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// AddPlaylistEntry inserts the video at position, shifting later entries
+// down. A negative or out of range position appends to the end.
+func (c sqlStore) AddPlaylistEntry(playlistID, videoID uuid.UUID, position int) error {
+	return c.editPlaylistEntries(playlistID, func(videoIDs []uuid.UUID) ([]uuid.UUID, error) {
+		for _, id := range videoIDs {
+			if id == videoID {
+				return nil, ErrAlreadyInPlaylist
+			}
+		}
+		if len(videoIDs) >= MaxPlaylistEntries {
+			return nil, ErrPlaylistFull
+		}
+		if position < 0 || position > len(videoIDs) {
+			position = len(videoIDs)
+		}
+		videoIDs = append(videoIDs, uuid.Nil)
+		copy(videoIDs[position+1:], videoIDs[position:])
+		videoIDs[position] = videoID
+		return videoIDs, nil
+	})
+}
+
+func (c sqlStore) RemovePlaylistEntry(playlistID, videoID uuid.UUID) error {
+	return c.editPlaylistEntries(playlistID, func(videoIDs []uuid.UUID) ([]uuid.UUID, error) {
+		i := indexOfVideo(videoIDs, videoID)
+		if i < 0 {
+			return nil, ErrPlaylistEntryNotFound
+		}
+		return append(videoIDs[:i], videoIDs[i+1:]...), nil
+	})
+}
+
+// MovePlaylistEntry moves the video to position, clamped to the playlist's
+// bounds, keeping the relative order of every other entry.
+func (c sqlStore) MovePlaylistEntry(playlistID, videoID uuid.UUID, position int) error {
+	return c.editPlaylistEntries(playlistID, func(videoIDs []uuid.UUID) ([]uuid.UUID, error) {
+		i := indexOfVideo(videoIDs, videoID)
+		if i < 0 {
+			return nil, ErrPlaylistEntryNotFound
+		}
+		videoIDs = append(videoIDs[:i], videoIDs[i+1:]...)
+		if position < 0 {
+			position = 0
+		}
+		if position > len(videoIDs) {
+			position = len(videoIDs)
+		}
+		videoIDs = append(videoIDs, uuid.Nil)
+		copy(videoIDs[position+1:], videoIDs[position:])
+		videoIDs[position] = videoID
+		return videoIDs, nil
+	})
+}
+
+func indexOfVideo(videoIDs []uuid.UUID, videoID uuid.UUID) int {
+	for i, id := range videoIDs {
+		if id == videoID {
+			return i
+		}
+	}
+	return -1
+}
+
+// editPlaylistEntries loads the playlist's video IDs in order, lets edit
+// rearrange them and writes the result back with positions renumbered from
+// zero, all in one transaction.
+//
+// Only videos that aren't in the trash are passed to edit, so positions and
+// the entry limit match what GetPlaylistEntries shows. Trashed videos keep
+// their entries, renumbered after the rest, and come back at the end of the
+// playlist if they are restored.
+func (c sqlStore) editPlaylistEntries(playlistID uuid.UUID, edit func([]uuid.UUID) ([]uuid.UUID, error)) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	SELECT pe.video_id, v.deleted_at IS NOT NULL
+	FROM playlist_entries pe
+	JOIN videos v ON v.id = pe.video_id
+	WHERE pe.playlist_id = ?
+	ORDER BY pe.position
+	`
+	rows, err := tx.Query(query, playlistID)
+	if err != nil {
+		return err
+	}
+	before := []uuid.UUID{}
+	trashed := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		var isTrashed bool
+		if err := rows.Scan(&id, &isTrashed); err != nil {
+			rows.Close()
+			return err
+		}
+		if isTrashed {
+			trashed = append(trashed, id)
+		} else {
+			before = append(before, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	after, err := edit(append([]uuid.UUID{}, before...))
+	if err != nil {
+		return err
+	}
+
+	existing := map[uuid.UUID]bool{}
+	for _, id := range before {
+		existing[id] = true
+	}
+	kept := map[uuid.UUID]bool{}
+	for position, id := range after {
+		kept[id] = true
+		if existing[id] {
+			_, err = tx.Exec("UPDATE playlist_entries SET position = ? WHERE playlist_id = ? AND video_id = ?", position, playlistID, id)
+		} else {
+			_, err = tx.Exec("INSERT INTO playlist_entries (playlist_id, video_id, position, added_at) VALUES (?, ?, ?, ?)", playlistID, id, position, timestamp())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i, id := range trashed {
+		_, err = tx.Exec("UPDATE playlist_entries SET position = ? WHERE playlist_id = ? AND video_id = ?", len(after)+i, playlistID, id)
+		if err != nil {
+			return err
+		}
+	}
+	for _, id := range before {
+		if !kept[id] {
+			_, err = tx.Exec("DELETE FROM playlist_entries WHERE playlist_id = ? AND video_id = ?", playlistID, id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec("UPDATE playlists SET updated_at = ? WHERE id = ?", timestamp(), playlistID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
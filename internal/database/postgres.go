@@ -0,0 +1,25 @@
+package database
+
+import (
+	"database/sql"
+
+	_ "github.com/lib/pq"
+)
+
+type PostgresClient struct {
+	sqlStore
+}
+
+// NewPostgresClient connects to dbURL without touching its schema.
+func NewPostgresClient(dbURL string) (PostgresClient, error) {
+	db, err := sql.Open(DriverPostgres, dbURL)
+	if err != nil {
+		return PostgresClient{}, err
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return PostgresClient{}, err
+	}
+	return PostgresClient{sqlStore{db: &rebindDB{DB: db, numbered: true}, driver: DriverPostgres}}, nil
+}
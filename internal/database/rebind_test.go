@@ -0,0 +1,84 @@
+package database
+
+import "testing"
+
+func TestNumberPlaceholders(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "placeholders",
+			query: "SELECT * FROM videos WHERE id = ? AND user_id = ?",
+			want:  "SELECT * FROM videos WHERE id = $1 AND user_id = $2",
+		},
+		{
+			name:  "no placeholders",
+			query: "SELECT 1",
+			want:  "SELECT 1",
+		},
+		{
+			name:  "string literal",
+			query: "SELECT '?' || title FROM videos WHERE id = ?",
+			want:  "SELECT '?' || title FROM videos WHERE id = $1",
+		},
+		{
+			name:  "escaped quote in literal",
+			query: "SELECT 'it''s ?', ?",
+			want:  "SELECT 'it''s ?', $1",
+		},
+		{
+			name:  "quoted identifier",
+			query: `SELECT "what?" FROM t WHERE a = ?`,
+			want:  `SELECT "what?" FROM t WHERE a = $1`,
+		},
+		{
+			name:  "line comment",
+			query: "SELECT ? -- why?\nFROM t WHERE a = ?",
+			want:  "SELECT $1 -- why?\nFROM t WHERE a = $2",
+		},
+		{
+			name:  "block comment",
+			query: "SELECT /* ? */ ? FROM t",
+			want:  "SELECT /* ? */ $1 FROM t",
+		},
+		{
+			name:  "jsonb operators",
+			query: "SELECT * FROM t WHERE data ?? 'key' AND data ??| ? AND data ??& ?",
+			want:  "SELECT * FROM t WHERE data ? 'key' AND data ?| $1 AND data ?& $2",
+		},
+		{
+			name:  "unterminated literal",
+			query: "SELECT ? WHERE a = 'oops?",
+			want:  "SELECT $1 WHERE a = 'oops?",
+		},
+		{
+			name:  "unterminated comment",
+			query: "SELECT ? /* ?",
+			want:  "SELECT $1 /* ?",
+		},
+		{
+			name:  "minus is not a comment",
+			query: "SELECT a - ? FROM t",
+			want:  "SELECT a - $1 FROM t",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := numberPlaceholders(tc.query)
+			if got != tc.want {
+				t.Errorf("numberPlaceholders(%q)\n got: %q\nwant: %q", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRebindLeavesSQLiteQueriesAlone(t *testing.T) {
+	db := &rebindDB{numbered: false}
+	query := "SELECT * FROM videos WHERE id = ?"
+	if got := db.rebind(query); got != query {
+		t.Errorf("rebind(%q) = %q, want it unchanged", query, got)
+	}
+}
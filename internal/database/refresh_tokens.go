@@ -1,36 +1,52 @@
 package database
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrRefreshTokenReused is returned when rotating a token that has already
+// been revoked, which means it was used twice.
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
 type RefreshToken struct {
 	CreateRefreshTokenParams
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	RevokedAt *time.Time `json:"revoked_at"`
+	// Rotated is set when the token was revoked by RotateRefreshToken rather
+	// than by a logout, so presenting it again means it was stolen.
+	Rotated bool `json:"rotated"`
+	// LastUsedAt is when the session was last used: the login or refresh
+	// that issued this token.
+	LastUsedAt time.Time `json:"last_used_at"`
 }
 
 type CreateRefreshTokenParams struct {
-	Token     string    `json:"token"`
-	UserID    uuid.UUID `json:"user_id"`
+	// Token is the raw token handed to the client. Only its hash is stored.
+	Token  string    `json:"token"`
+	UserID uuid.UUID `json:"user_id"`
+	// ExpiresAt is inherited from the first token in the family by
+	// RotateRefreshToken, so rotation never extends a session.
 	ExpiresAt time.Time `json:"expires_at"`
+	// FamilyID groups the tokens issued by rotation from a single login.
+	// CreateRefreshToken starts a new family when it is uuid.Nil.
+	FamilyID uuid.UUID `json:"family_id"`
+	// UserAgent and IP describe the client the token was issued to.
+	UserAgent string `json:"user_agent"`
+	IP        string `json:"ip"`
 }
 
-func (c Client) CreateRefreshToken(params CreateRefreshTokenParams) (RefreshToken, error) {
-	query := `
-		INSERT INTO refresh_tokens (
-			token,
-			created_at,
-			updated_at,
-			user_id,
-			expires_at
-		) VALUES (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?)
-	`
-	_, err := c.db.Exec(query, params.Token, params.UserID.String(), params.ExpiresAt)
+func (c sqlStore) CreateRefreshToken(params CreateRefreshTokenParams) (RefreshToken, error) {
+	if params.FamilyID == uuid.Nil {
+		params.FamilyID = uuid.New()
+	}
+	_, err := c.db.Exec(insertRefreshTokenQuery, hashToken(params.Token), params.UserID, params.ExpiresAt, params.FamilyID, params.UserAgent, params.IP)
 	if err != nil {
 		return RefreshToken{}, err
 	}
@@ -38,46 +54,149 @@ func (c Client) CreateRefreshToken(params CreateRefreshTokenParams) (RefreshToke
 	return c.GetRefreshToken(params.Token)
 }
 
-func (c Client) RevokeRefreshToken(token string) error {
+// hashToken returns the hex SHA-256 of a refresh token or API key, which is
+// what the database stores and is searched by. Both are 256 random bits, so
+// a fast unsalted hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashExistingRefreshTokens is the data half of migration 0014: it replaces
+// the raw tokens stored before then with their hashes.
+func hashExistingRefreshTokens(c sqlStore, tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT token_hash FROM refresh_tokens")
+	if err != nil {
+		return err
+	}
+	var tokens []string
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			rows.Close()
+			return err
+		}
+		tokens = append(tokens, token)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query := c.db.rebind("UPDATE refresh_tokens SET token_hash = ? WHERE token_hash = ?")
+	for _, token := range tokens {
+		_, err := tx.Exec(query, hashToken(token), token)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const insertRefreshTokenQuery = `
+	INSERT INTO refresh_tokens (
+		token_hash,
+		created_at,
+		updated_at,
+		user_id,
+		expires_at,
+		family_id,
+		user_agent,
+		ip,
+		last_used_at
+	) VALUES (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+`
+
+// RotateRefreshToken revokes the old token and issues params in its place,
+// in the same family and with the old token's expiry. It returns
+// ErrRefreshTokenReused if the old token was already revoked, including by a
+// concurrent rotation or logout.
+func (c sqlStore) RotateRefreshToken(oldToken string, params CreateRefreshTokenParams) (RefreshToken, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP, rotated = TRUE
+		WHERE token_hash = ? AND revoked_at IS NULL
+	`, hashToken(oldToken))
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	if n == 0 {
+		return RefreshToken{}, ErrRefreshTokenReused
+	}
+
+	err = tx.QueryRow("SELECT expires_at FROM refresh_tokens WHERE token_hash = ?", hashToken(oldToken)).Scan(&params.ExpiresAt)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	_, err = tx.Exec(insertRefreshTokenQuery, hashToken(params.Token), params.UserID, params.ExpiresAt, params.FamilyID, params.UserAgent, params.IP)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	return c.GetRefreshToken(params.Token)
+}
+
+func (c sqlStore) RevokeRefreshToken(token string) error {
 	query := `
 		UPDATE refresh_tokens
 		SET revoked_at = CURRENT_TIMESTAMP
-		WHERE token = ?
+		WHERE token_hash = ?
 	`
-	_, err := c.db.Exec(query, token)
+	_, err := c.db.Exec(query, hashToken(token))
 	return err
 }
 
-func (c Client) GetRefreshToken(token string) (RefreshToken, error) {
+// RevokeRefreshTokenFamily revokes every live token in the family.
+func (c sqlStore) RevokeRefreshTokenFamily(familyID uuid.UUID) error {
 	query := `
-		SELECT token, created_at, updated_at, user_id, expires_at, revoked_at
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = ? AND revoked_at IS NULL
+	`
+	_, err := c.db.Exec(query, familyID)
+	return err
+}
+
+func (c sqlStore) GetRefreshToken(token string) (RefreshToken, error) {
+	query := `
+		SELECT created_at, updated_at, user_id, expires_at, revoked_at, rotated, family_id, user_agent, ip, last_used_at
 		FROM refresh_tokens
-		WHERE token = ?
+		WHERE token_hash = ?
 	`
 	var rt RefreshToken
-	var userID string
-	err := c.db.QueryRow(query, token).
-		Scan(&rt.Token, &rt.CreatedAt, &rt.UpdatedAt, &userID, &rt.ExpiresAt, &rt.RevokedAt)
+	err := c.db.QueryRow(query, hashToken(token)).
+		Scan(&rt.CreatedAt, &rt.UpdatedAt, &rt.UserID, &rt.ExpiresAt, &rt.RevokedAt, &rt.Rotated, &rt.FamilyID, &rt.UserAgent, &rt.IP, &rt.LastUsedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return RefreshToken{}, nil
+		if errors.Is(err, sql.ErrNoRows) {
+			return RefreshToken{}, ErrNotFound
 		}
 		return RefreshToken{}, err
 	}
 
-	rt.UserID, err = uuid.Parse(userID)
-	if err != nil {
-		return RefreshToken{}, err
-	}
+	rt.Token = token
 
 	return rt, nil
 }
 
-func (c Client) DeleteRefreshToken(token string) error {
+func (c sqlStore) DeleteRefreshToken(token string) error {
 	query := `
 		DELETE FROM refresh_tokens
-		WHERE token = ?
+		WHERE token_hash = ?
 	`
-	_, err := c.db.Exec(query, token)
+	_, err := c.db.Exec(query, hashToken(token))
 	return err
 }
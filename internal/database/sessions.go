@@ -0,0 +1,100 @@
+package database
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login as the user sees it: a refresh token family, described
+// by its newest token.
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+}
+
+// GetSessions returns the user's live sessions, most recently used first.
+// Rotation revokes each token as it issues the next, so every family has at
+// most one unrevoked token. The session started when the family's first
+// token was issued.
+func (c sqlStore) GetSessions(userID uuid.UUID) ([]Session, error) {
+	query := `
+		SELECT
+			rt.family_id,
+			first.created_at,
+			rt.last_used_at,
+			rt.expires_at,
+			rt.user_agent,
+			rt.ip
+		FROM refresh_tokens rt
+		JOIN refresh_tokens first ON first.token_hash = (
+			SELECT f.token_hash
+			FROM refresh_tokens f
+			WHERE f.family_id = rt.family_id
+			ORDER BY f.created_at, f.token_hash
+			LIMIT 1
+		)
+		WHERE rt.user_id = ? AND rt.revoked_at IS NULL
+		ORDER BY rt.last_used_at DESC
+	`
+	rows, err := c.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.UserAgent, &session.IP)
+		if err != nil {
+			return nil, err
+		}
+		if now.After(session.ExpiresAt) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes the user's session with the given ID. It returns
+// ErrNotFound if the user has no live token in that session.
+func (c sqlStore) RevokeSession(userID, sessionID uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND family_id = ? AND revoked_at IS NULL
+	`
+	result, err := c.db.Exec(query, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeAllSessions revokes every refresh token the user holds.
+func (c sqlStore) RevokeAllSessions(userID uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND revoked_at IS NULL
+	`
+	_, err := c.db.Exec(query, userID)
+	return err
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type SQLiteClient struct {
+	sqlStore
+}
+
+// NewSQLiteClient opens the database at pathToDB without touching its schema.
+// Foreign key enforcement is turned on for every connection.
+func NewSQLiteClient(pathToDB string) (SQLiteClient, error) {
+	sep := "?"
+	if strings.Contains(pathToDB, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open(DriverSQLite, pathToDB+sep+"_foreign_keys=on")
+	if err != nil {
+		return SQLiteClient{}, err
+	}
+	return SQLiteClient{sqlStore{db: &rebindDB{DB: db}, driver: DriverSQLite}}, nil
+}
+
+// MigrateUp checks that SQLite was built with FTS5, which the video search
+// migration needs, before applying pending migrations.
+func (c SQLiteClient) MigrateUp() error {
+	var fts5 bool
+	err := c.db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5)
+	if err != nil {
+		return err
+	}
+	if !fts5 {
+		return errors.New("sqlite was built without FTS5; build with -tags sqlite_fts5")
+	}
+	return c.sqlStore.MigrateUp()
+}
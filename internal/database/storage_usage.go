@@ -0,0 +1,40 @@
+package database
+
+import "github.com/google/uuid"
+
+// StorageUsage sums up what a user has stored. Trashed videos don't count
+// towards VideoCount, but their files take up space until they are purged.
+type StorageUsage struct {
+	VideoCount     int   `json:"video_count"`
+	VideoBytes     int64 `json:"video_bytes"`
+	ThumbnailBytes int64 `json:"thumbnail_bytes"`
+	TrashBytes     int64 `json:"trash_bytes"`
+	AvatarBytes    int64 `json:"avatar_bytes"`
+	TotalBytes     int64 `json:"total_bytes"`
+}
+
+func (c sqlStore) GetStorageUsage(userID uuid.UUID) (StorageUsage, error) {
+	query := `
+	SELECT
+		COUNT(CASE WHEN deleted_at IS NULL THEN 1 END),
+		COALESCE(SUM(CASE WHEN deleted_at IS NULL THEN video_size ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN deleted_at IS NULL THEN thumbnail_size ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN deleted_at IS NOT NULL THEN video_size + thumbnail_size ELSE 0 END), 0),
+		COALESCE((SELECT avatar_size FROM users WHERE id = ?), 0)
+	FROM videos
+	WHERE user_id = ?
+	`
+	var usage StorageUsage
+	err := c.db.QueryRow(query, userID, userID).Scan(
+		&usage.VideoCount,
+		&usage.VideoBytes,
+		&usage.ThumbnailBytes,
+		&usage.TrashBytes,
+		&usage.AvatarBytes,
+	)
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	usage.TotalBytes = usage.VideoBytes + usage.ThumbnailBytes + usage.TrashBytes + usage.AvatarBytes
+	return usage, nil
+}
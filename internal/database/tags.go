@@ -0,0 +1,204 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxTagsPerVideo = 10
+	MaxTagLength    = 30
+)
+
+var (
+	ErrInvalidTag  = errors.New("invalid tag")
+	ErrTooManyTags = fmt.Errorf("a video can have at most %d tags", MaxTagsPerVideo)
+	ErrTagNotFound = fmt.Errorf("video doesn't have that tag: %w", ErrNotFound)
+)
+
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTag case-folds a tag, trims it and collapses inner whitespace so
+// "  Cooking   Tips" and "cooking tips" are the same tag.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+	if tag == "" {
+		return "", fmt.Errorf("%w: tags can't be empty", ErrInvalidTag)
+	}
+	if utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", fmt.Errorf("%w: tags can't be longer than %d characters", ErrInvalidTag, MaxTagLength)
+	}
+	return tag, nil
+}
+
+// normalizeTags normalizes and de-duplicates tags, keeping them sorted.
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxTagsPerVideo {
+		return nil, ErrTooManyTags
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func (c sqlStore) GetVideoTags(videoID uuid.UUID) ([]string, error) {
+	query := `
+	SELECT t.name
+	FROM tags t
+	JOIN video_tags vt ON vt.tag_id = t.id
+	WHERE vt.video_id = ?
+	ORDER BY t.name
+	`
+	rows, err := c.db.Query(query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// SetVideoTags replaces the video's tags. Tags belong to the video's owner
+// and are created on first use.
+func (c sqlStore) SetVideoTags(video Video, tags []string) ([]string, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM video_tags WHERE video_id = ?", video.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		_, err = tx.Exec(`
+		INSERT INTO tags (id, user_id, name) VALUES (?, ?, ?)
+		ON CONFLICT (user_id, name) DO NOTHING
+		`, uuid.New(), video.UserID, tag)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`
+		INSERT INTO video_tags (video_id, tag_id)
+		SELECT ?, id FROM tags WHERE user_id = ? AND name = ?
+		`, video.ID, video.UserID, tag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = deleteUnusedTags(tx, video.UserID)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (c sqlStore) RemoveVideoTag(video Video, tag string) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+	DELETE FROM video_tags
+	WHERE video_id = ? AND tag_id IN (SELECT id FROM tags WHERE user_id = ? AND name = ?)
+	`, video.ID, video.UserID, tag)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTagNotFound
+	}
+
+	err = deleteUnusedTags(tx, video.UserID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteUnusedTags(tx *rebindTx, userID uuid.UUID) error {
+	_, err := tx.Exec(`
+	DELETE FROM tags
+	WHERE user_id = ? AND id NOT IN (SELECT tag_id FROM video_tags)
+	`, userID)
+	return err
+}
+
+// GetTags lists the user's tags with how many of their videos use each.
+func (c sqlStore) GetTags(userID uuid.UUID) ([]TagCount, error) {
+	query := `
+	SELECT t.name, COUNT(v.id)
+	FROM tags t
+	LEFT JOIN video_tags vt ON vt.tag_id = t.id
+	LEFT JOIN videos v ON v.id = vt.video_id AND v.deleted_at IS NULL
+	WHERE t.user_id = ?
+	GROUP BY t.name
+	ORDER BY COUNT(v.id) DESC, t.name
+	`
+	rows, err := c.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
@@ -9,18 +9,28 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   *string   `json:"avatar_url"`
+	// AvatarSize is the size in bytes of the avatar file.
+	AvatarSize int64 `json:"avatar_size"`
+	// Plan names the upload limits that apply to the user.
+	Plan string `json:"plan"`
+	// IsAdmin users are granted the admin scope when they log in.
+	IsAdmin bool `json:"is_admin"`
 	CreateUserParams
 }
 
 type CreateUserParams struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email string `json:"email"`
+	// Password is the bcrypt hash, never the password itself. It's left out
+	// of JSON so a user can be written to a response as is.
+	Password string `json:"-"`
 }
 
-func (c Client) GetUsers() ([]User, error) {
+func (c sqlStore) GetUsers() ([]User, error) {
 	query := `
 		SELECT
 			id,
@@ -37,12 +47,7 @@ func (c Client) GetUsers() ([]User, error) {
 	users := []User{}
 	for rows.Next() {
 		var user User
-		var id string
-		if err := rows.Scan(&id, &user.Email); err != nil {
-			return nil, err
-		}
-		user.ID, err = uuid.Parse(id)
-		if err != nil {
+		if err := rows.Scan(&user.ID, &user.Email); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -51,54 +56,24 @@ func (c Client) GetUsers() ([]User, error) {
 	return users, nil
 }
 
-func (c Client) GetUserByEmail(email string) (User, error) {
+func (c sqlStore) GetUserByEmail(email string) (User, error) {
 	query := `
-		SELECT id, created_at, updated_at, email, password
+		SELECT id, created_at, updated_at, email, password, display_name, avatar_url, avatar_size, plan, is_admin
 		FROM users
 		WHERE email = ?
 	`
 	var user User
-	var id string
-	err := c.db.QueryRow(query, email).Scan(&id, &user.CreatedAt, &user.UpdatedAt, &user.Email, &user.Password)
+	err := c.db.QueryRow(query, email).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Email, &user.Password, &user.DisplayName, &user.AvatarURL, &user.AvatarSize, &user.Plan, &user.IsAdmin)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return User{}, nil
+			return User{}, ErrNotFound
 		}
 		return User{}, err
 	}
-	user.ID, err = uuid.Parse(id)
-	if err != nil {
-		return User{}, err
-	}
 	return user, nil
 }
 
-func (c Client) GetUserByRefreshToken(token string) (*User, error) {
-	query := `
-		SELECT u.id, u.email, u.created_at, u.updated_at, u.password
-		FROM users u
-		JOIN refresh_tokens rt ON u.id = rt.user_id
-		WHERE rt.token = ?
-	`
-
-	var user User
-	var id string
-	err := c.db.QueryRow(query, token).Scan(&id, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Password)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
-		return nil, err
-	}
-	user.ID, err = uuid.Parse(id)
-	if err != nil {
-		return nil, err
-	}
-
-	return &user, nil
-}
-
-func (c Client) CreateUser(params CreateUserParams) (*User, error) {
+func (c sqlStore) CreateUser(params CreateUserParams) (*User, error) {
 	id := uuid.New()
 
 	query := `
@@ -107,7 +82,7 @@ func (c Client) CreateUser(params CreateUserParams) (*User, error) {
 		VALUES
 		    (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?)
 	`
-	_, err := c.db.Exec(query, id.String(), params.Email, params.Password)
+	_, err := c.db.Exec(query, id, params.Email, params.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -115,33 +90,69 @@ func (c Client) CreateUser(params CreateUserParams) (*User, error) {
 	return c.GetUser(id)
 }
 
-func (c Client) GetUser(id uuid.UUID) (*User, error) {
+func (c sqlStore) GetUser(id uuid.UUID) (*User, error) {
 	query := `
-		SELECT id, created_at, updated_at, email, password
+		SELECT id, created_at, updated_at, email, password, display_name, avatar_url, avatar_size, plan, is_admin
 		FROM users
 		WHERE id = ?
 	`
 	var user User
-	var idStr string
-	err := c.db.QueryRow(query, id.String()).Scan(&idStr, &user.CreatedAt, &user.UpdatedAt, &user.Email, &user.Password)
+	err := c.db.QueryRow(query, id).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Email, &user.Password, &user.DisplayName, &user.AvatarURL, &user.AvatarSize, &user.Plan, &user.IsAdmin)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
-	user.ID, err = uuid.Parse(idStr)
+	return &user, nil
+}
+
+type UpdateUserProfileParams struct {
+	DisplayName string
+	AvatarURL   *string
+	AvatarSize  int64
+}
+
+func (c sqlStore) UpdateUserProfile(id uuid.UUID, params UpdateUserProfileParams) (*User, error) {
+	query := `
+		UPDATE users
+		SET updated_at = CURRENT_TIMESTAMP, display_name = ?, avatar_url = ?, avatar_size = ?
+		WHERE id = ?
+	`
+	_, err := c.db.Exec(query, params.DisplayName, params.AvatarURL, params.AvatarSize, id)
 	if err != nil {
 		return nil, err
 	}
-	return &user, nil
+	return c.GetUser(id)
+}
+
+// SetUserAdmin grants or takes away the admin scope. It returns ErrNotFound
+// if there is no such user.
+func (c sqlStore) SetUserAdmin(id uuid.UUID, isAdmin bool) error {
+	query := `
+		UPDATE users
+		SET updated_at = CURRENT_TIMESTAMP, is_admin = ?
+		WHERE id = ?
+	`
+	result, err := c.db.Exec(query, isAdmin, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
-func (c Client) DeleteUser(id uuid.UUID) error {
+func (c sqlStore) DeleteUser(id uuid.UUID) error {
 	query := `
 		DELETE FROM users
 		WHERE id = ?
 	`
-	_, err := c.db.Exec(query, id.String())
+	_, err := c.db.Exec(query, id)
 	return err
 }
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type VideoSort string
+
+const (
+	VideoSortCreated VideoSort = "created"
+	VideoSortUpdated VideoSort = "updated"
+	VideoSortTitle   VideoSort = "title"
+)
+
+const (
+	VideoStatusPending = "pending"
+	VideoStatusReady   = "ready"
+)
+
+const (
+	DefaultVideoPageSize = 50
+	MaxVideoPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type ListVideosParams struct {
+	// UserID limits results to one user's videos. uuid.Nil lists everyone's.
+	UserID     uuid.UUID
+	Limit      int
+	Cursor     string
+	Sort       VideoSort
+	Descending bool
+
+	// Filters. Zero values mean "don't filter".
+	Visibility    string
+	Tag           string
+	Status        string
+	Orientation   string
+	HasThumbnail  *bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+type VideoPage struct {
+	Videos []Video
+	// NextCursor is empty when there are no more results.
+	NextCursor string
+}
+
+// videoCursor marks the last row of a page. It is handed to clients as an
+// opaque base64 string.
+type videoCursor struct {
+	Sort  VideoSort `json:"s"`
+	Desc  bool      `json:"d"`
+	Title string    `json:"t,omitempty"`
+	Time  time.Time `json:"v,omitempty"`
+	ID    uuid.UUID `json:"id"`
+}
+
+func encodeVideoCursor(cursor videoCursor) (string, error) {
+	dat, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(dat), nil
+}
+
+func decodeVideoCursor(s string) (videoCursor, error) {
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return videoCursor{}, ErrInvalidCursor
+	}
+	var cursor videoCursor
+	err = json.Unmarshal(dat, &cursor)
+	if err != nil {
+		return videoCursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+func videoSortColumn(sort VideoSort) (string, error) {
+	switch sort {
+	case VideoSortCreated:
+		return "created_at", nil
+	case VideoSortUpdated:
+		return "updated_at", nil
+	case VideoSortTitle:
+		return "title", nil
+	default:
+		return "", fmt.Errorf("unknown sort %q", sort)
+	}
+}
+
+// ListVideos returns one page of videos using keyset pagination on
+// the sort column and id.
+func (c sqlStore) ListVideos(params ListVideosParams) (VideoPage, error) {
+	if params.Sort == "" {
+		params.Sort = VideoSortCreated
+	}
+	column, err := videoSortColumn(params.Sort)
+	if err != nil {
+		return VideoPage{}, err
+	}
+	if params.Limit <= 0 {
+		params.Limit = DefaultVideoPageSize
+	}
+	if params.Limit > MaxVideoPageSize {
+		params.Limit = MaxVideoPageSize
+	}
+
+	where := []string{"deleted_at IS NULL"}
+	args := []any{}
+
+	if params.UserID != uuid.Nil {
+		where = append(where, "user_id = ?")
+		args = append(args, params.UserID)
+	}
+	if params.Visibility != "" {
+		where = append(where, "visibility = ?")
+		args = append(args, params.Visibility)
+	}
+
+	if params.Tag != "" {
+		tag, err := NormalizeTag(params.Tag)
+		if err != nil {
+			return VideoPage{}, err
+		}
+		where = append(where, `id IN (
+		SELECT vt.video_id FROM video_tags vt
+		JOIN tags t ON t.id = vt.tag_id
+		WHERE t.name = ?
+	)`)
+		args = append(args, tag)
+	}
+
+	switch params.Status {
+	case "":
+	case VideoStatusPending:
+		where = append(where, "video_url IS NULL")
+	case VideoStatusReady:
+		where = append(where, "video_url IS NOT NULL")
+	default:
+		return VideoPage{}, fmt.Errorf("unknown status %q", params.Status)
+	}
+	if params.Orientation != "" {
+		where = append(where, "orientation = ?")
+		args = append(args, params.Orientation)
+	}
+	if params.HasThumbnail != nil {
+		if *params.HasThumbnail {
+			where = append(where, "thumbnail_url IS NOT NULL")
+		} else {
+			where = append(where, "thumbnail_url IS NULL")
+		}
+	}
+	if params.CreatedAfter != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, params.CreatedAfter.UTC())
+	}
+	if params.CreatedBefore != nil {
+		where = append(where, "created_at < ?")
+		args = append(args, params.CreatedBefore.UTC())
+	}
+
+	cmp, direction := ">", "ASC"
+	if params.Descending {
+		cmp, direction = "<", "DESC"
+	}
+
+	if params.Cursor != "" {
+		cursor, err := decodeVideoCursor(params.Cursor)
+		if err != nil {
+			return VideoPage{}, err
+		}
+		if cursor.Sort != params.Sort || cursor.Desc != params.Descending {
+			return VideoPage{}, ErrInvalidCursor
+		}
+		var value any = cursor.Time
+		if params.Sort == VideoSortTitle {
+			value = cursor.Title
+		}
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, cmp))
+		args = append(args, value, value, cursor.ID)
+	}
+
+	whereClause := "WHERE " + strings.Join(where, " AND ")
+	query := fmt.Sprintf(`
+	SELECT`+videoColumns+`
+	FROM videos
+	%s
+	ORDER BY %s %s, id %s
+	LIMIT ?
+	`, whereClause, column, direction, direction)
+	// Fetch one extra row to learn whether there is another page.
+	args = append(args, params.Limit+1)
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return VideoPage{}, err
+	}
+	videos, err := scanVideos(rows)
+	if err != nil {
+		return VideoPage{}, err
+	}
+
+	page := VideoPage{Videos: videos}
+	if len(videos) > params.Limit {
+		page.Videos = videos[:params.Limit]
+		last := page.Videos[len(page.Videos)-1]
+		cursor := videoCursor{
+			Sort: params.Sort,
+			Desc: params.Descending,
+			ID:   last.ID,
+		}
+		switch params.Sort {
+		case VideoSortCreated:
+			cursor.Time = last.CreatedAt
+		case VideoSortUpdated:
+			cursor.Time = last.UpdatedAt
+		case VideoSortTitle:
+			cursor.Title = last.Title
+		}
+		page.NextCursor, err = encodeVideoCursor(cursor)
+		if err != nil {
+			return VideoPage{}, err
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Search results wrap matched terms in these markers. The rest of the text
+// is returned as stored, so clients must escape it before rendering as HTML.
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+var ErrEmptySearchQuery = errors.New("empty search query")
+
+type SearchVideosParams struct {
+	UserID uuid.UUID
+	Query  string
+	Limit  int
+	Cursor string
+}
+
+type VideoSearchResult struct {
+	Video
+	TitleHighlight       string  `json:"title_highlight"`
+	DescriptionHighlight string  `json:"description_highlight"`
+	Rank                 float64 `json:"rank"`
+}
+
+type VideoSearchPage struct {
+	Results []VideoSearchResult
+	// NextCursor is empty when there are no more results.
+	NextCursor string
+}
+
+// searchCursor marks the last result of a page. Results are ordered by rank
+// and then id, so the next page starts after that pair. It is handed to
+// clients as an opaque base64 string like videoCursor.
+type searchCursor struct {
+	Rank float64   `json:"r"`
+	ID   uuid.UUID `json:"id"`
+}
+
+func encodeSearchCursor(cursor searchCursor) (string, error) {
+	dat, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(dat), nil
+}
+
+func decodeSearchCursor(s string) (*searchCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor searchCursor
+	err = json.Unmarshal(dat, &cursor)
+	if err != nil || cursor.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+func normalizeSearchParams(params *SearchVideosParams) (*searchCursor, error) {
+	params.Query = strings.TrimSpace(params.Query)
+	if params.Query == "" {
+		return nil, ErrEmptySearchQuery
+	}
+	if params.Limit <= 0 {
+		params.Limit = DefaultVideoPageSize
+	}
+	if params.Limit > MaxVideoPageSize {
+		params.Limit = MaxVideoPageSize
+	}
+	return decodeSearchCursor(params.Cursor)
+}
+
+// querySearchResults pages through matches, a query selecting the video
+// columns, the two highlights and a rank column, using keyset pagination on
+// rank and id.
+func (c sqlStore) querySearchResults(matches string, limit int, cursor *searchCursor, args ...any) (VideoSearchPage, error) {
+	query := `SELECT * FROM (` + matches + `) AS results`
+	if cursor != nil {
+		query += `
+	WHERE rank < ? OR (rank = ? AND id > ?)`
+		args = append(args, cursor.Rank, cursor.Rank, cursor.ID)
+	}
+	// Fetch one extra row to learn whether there is another page.
+	query += `
+	ORDER BY rank DESC, id
+	LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return VideoSearchPage{}, err
+	}
+	defer rows.Close()
+
+	results := []VideoSearchResult{}
+	for rows.Next() {
+		var result VideoSearchResult
+		var err error
+		result.Video, err = scanVideo(rows, &result.TitleHighlight, &result.DescriptionHighlight, &result.Rank)
+		if err != nil {
+			return VideoSearchPage{}, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return VideoSearchPage{}, err
+	}
+
+	page := VideoSearchPage{Results: results}
+	if len(results) > limit {
+		page.Results = results[:limit]
+		last := page.Results[limit-1]
+		page.NextCursor, err = encodeSearchCursor(searchCursor{Rank: last.Rank, ID: last.ID})
+		if err != nil {
+			return VideoSearchPage{}, err
+		}
+	}
+	return page, nil
+}
+
+// SearchVideos runs a ranked full-text search over the user's video titles
+// and descriptions using the FTS5 index kept in sync by triggers.
+func (c SQLiteClient) SearchVideos(params SearchVideosParams) (VideoSearchPage, error) {
+	cursor, err := normalizeSearchParams(&params)
+	if err != nil {
+		return VideoSearchPage{}, err
+	}
+
+	matches := fmt.Sprintf(`
+	SELECT`+qualifiedVideoColumns("v")+`,
+		highlight(videos_fts, 0, '%[1]s', '%[2]s') AS title_highlight,
+		snippet(videos_fts, 1, '%[1]s', '%[2]s', '…', 24) AS description_highlight,
+		-bm25(videos_fts, 10.0, 1.0, 0.0) AS rank
+	FROM videos_fts
+	JOIN videos v ON v.id = videos_fts.video_id
+	WHERE videos_fts MATCH ? AND v.user_id = ? AND v.deleted_at IS NULL
+	`, HighlightStart, HighlightEnd)
+
+	return c.querySearchResults(matches, params.Limit, cursor, ftsMatchQuery(params.Query), params.UserID)
+}
+
+// ftsMatchQuery turns free text into an FTS5 query that matches every word,
+// treating the last one as a prefix so results show up while typing. Words
+// are quoted so FTS5 operators in user input are taken literally.
+func ftsMatchQuery(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	words[len(words)-1] += "*"
+	return strings.Join(words, " ")
+}
+
+// SearchVideos runs a ranked full-text search over the user's video titles
+// and descriptions using the generated tsvector column.
+func (c PostgresClient) SearchVideos(params SearchVideosParams) (VideoSearchPage, error) {
+	cursor, err := normalizeSearchParams(&params)
+	if err != nil {
+		return VideoSearchPage{}, err
+	}
+
+	// ts_rank returns a real. It's widened so the rank in a cursor compares
+	// equal to the one it came from.
+	matches := fmt.Sprintf(`
+	SELECT`+qualifiedVideoColumns("v")+`,
+		ts_headline('english', v.title, q, 'StartSel=%[1]s, StopSel=%[2]s, HighlightAll=true') AS title_highlight,
+		ts_headline('english', v.description, q, 'StartSel=%[1]s, StopSel=%[2]s, MaxFragments=1') AS description_highlight,
+		ts_rank(v.search, q)::double precision AS rank
+	FROM videos v, websearch_to_tsquery('english', ?) q
+	WHERE v.search @@ q AND v.user_id = ? AND v.deleted_at IS NULL
+	`, HighlightStart, HighlightEnd)
+
+	return c.querySearchResults(matches, params.Limit, cursor, params.Query, params.UserID)
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TrashVideo moves the video to the trash. It disappears from lists, search
+// and playlists until it is restored or purged.
+func (c sqlStore) TrashVideo(id uuid.UUID) error {
+	now := timestamp()
+	query := `
+	UPDATE videos
+	SET deleted_at = ?, updated_at = ?
+	WHERE id = ? AND deleted_at IS NULL
+	`
+	_, err := c.db.Exec(query, now, now, id)
+	return err
+}
+
+// GetTrashedVideo returns the trashed video with the given ID, or
+// ErrNotFound if it isn't in the trash.
+func (c sqlStore) GetTrashedVideo(id uuid.UUID) (Video, error) {
+	return c.getVideo(id, true)
+}
+
+// GetTrashedVideos returns the user's trashed videos, most recently deleted
+// first.
+func (c sqlStore) GetTrashedVideos(userID uuid.UUID) ([]Video, error) {
+	query := `
+	SELECT` + videoColumns + `
+	FROM videos
+	WHERE user_id = ? AND deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC, id
+	`
+	rows, err := c.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanVideos(rows)
+}
+
+// RestoreVideo takes the video out of the trash and returns it.
+func (c sqlStore) RestoreVideo(id uuid.UUID) (Video, error) {
+	query := `
+	UPDATE videos
+	SET deleted_at = NULL, updated_at = ?
+	WHERE id = ? AND deleted_at IS NOT NULL
+	`
+	_, err := c.db.Exec(query, timestamp(), id)
+	if err != nil {
+		return Video{}, err
+	}
+	return c.GetVideo(id)
+}
+
+// GetVideosTrashedBefore returns every video that was moved to the trash
+// before the cutoff, across all users.
+func (c sqlStore) GetVideosTrashedBefore(cutoff time.Time) ([]Video, error) {
+	query := `
+	SELECT` + videoColumns + `
+	FROM videos
+	WHERE deleted_at IS NOT NULL AND deleted_at < ?
+	ORDER BY deleted_at
+	`
+	rows, err := c.db.Query(query, cutoff.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanVideos(rows)
+}
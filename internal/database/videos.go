@@ -3,71 +3,146 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrConflict is returned when a conditional update finds the row has
+// changed since it was read.
+var ErrConflict = errors.New("video was modified concurrently")
+
 type Video struct {
 	ID           uuid.UUID `json:"id"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	ThumbnailURL *string   `json:"thumbnail_url"`
 	VideoURL     *string   `json:"video_url"`
+	Orientation  *string   `json:"orientation"`
+	// Sizes in bytes of the stored video and thumbnail files.
+	VideoSize     int64 `json:"video_size"`
+	ThumbnailSize int64 `json:"thumbnail_size"`
+	// DeletedAt is set while the video is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 	CreateVideoParams
 }
 
 type CreateVideoParams struct {
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
+	Visibility  string    `json:"visibility"`
 	UserID      uuid.UUID `json:"user_id"`
 }
 
-func (c Client) GetVideos(userID uuid.UUID) ([]Video, error) {
-	query := `
-	SELECT
-		id,
-		created_at,
-		updated_at,
-		title,
-		description,
-		thumbnail_url,
-		video_url,
-		user_id
-	FROM videos
-	WHERE user_id = ?
-	ORDER BY created_at DESC
-	`
+// Who can see a video:
+//   - private: only the owner
+//   - unlisted: anyone with the video's ID
+//   - public: anyone, and it appears in public listings
+const (
+	VisibilityPrivate  = "private"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPublic   = "public"
+)
 
-	rows, err := c.db.Query(query, userID)
-	if err != nil {
-		return nil, err
+func IsValidVisibility(visibility string) bool {
+	switch visibility {
+	case VisibilityPrivate, VisibilityUnlisted, VisibilityPublic:
+		return true
+	default:
+		return false
+	}
+}
+
+var videoColumnNames = []string{
+	"id",
+	"created_at",
+	"updated_at",
+	"title",
+	"description",
+	"thumbnail_url",
+	"video_url",
+	"orientation",
+	"video_size",
+	"thumbnail_size",
+	"visibility",
+	"user_id",
+	"deleted_at",
+}
+
+var videoColumns = qualifiedVideoColumns("")
+
+// qualifiedVideoColumns lists the columns read by scanVideo, prefixed with
+// the given table alias for queries that join other tables.
+func qualifiedVideoColumns(alias string) string {
+	if alias != "" {
+		alias += "."
 	}
+	return "\n\t\t" + alias + strings.Join(videoColumnNames, ",\n\t\t"+alias)
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanVideo reads the columns listed in videoColumnNames, followed by any
+// extra columns the query selected into extra.
+func scanVideo(row rowScanner, extra ...any) (Video, error) {
+	var video Video
+	dest := []any{
+		&video.ID,
+		&video.CreatedAt,
+		&video.UpdatedAt,
+		&video.Title,
+		&video.Description,
+		&video.ThumbnailURL,
+		&video.VideoURL,
+		&video.Orientation,
+		&video.VideoSize,
+		&video.ThumbnailSize,
+		&video.Visibility,
+		&video.UserID,
+		&video.DeletedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	return video, err
+}
+
+func scanVideos(rows *sql.Rows) ([]Video, error) {
 	defer rows.Close()
 
 	videos := []Video{}
 	for rows.Next() {
-		var video Video
-		if err := rows.Scan(
-			&video.ID,
-			&video.CreatedAt,
-			&video.UpdatedAt,
-			&video.Title,
-			&video.Description,
-			&video.ThumbnailURL,
-			&video.VideoURL,
-			&video.UserID,
-		); err != nil {
+		video, err := scanVideo(rows)
+		if err != nil {
 			return nil, err
 		}
 		videos = append(videos, video)
 	}
+	return videos, rows.Err()
+}
+
+func (c sqlStore) GetVideos(userID uuid.UUID) ([]Video, error) {
+	query := `
+	SELECT` + videoColumns + `
+	FROM videos
+	WHERE user_id = ? AND deleted_at IS NULL
+	ORDER BY created_at DESC
+	`
 
-	return videos, nil
+	rows, err := c.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanVideos(rows)
 }
 
-func (c Client) CreateVideo(params CreateVideoParams) (Video, error) {
+func (c sqlStore) CreateVideo(params CreateVideoParams) (Video, error) {
 	id := uuid.New()
+	now := timestamp()
+	if params.Visibility == "" {
+		params.Visibility = VisibilityPrivate
+	}
 	query := `
 	INSERT INTO videos (
 		id,
@@ -75,10 +150,11 @@ func (c Client) CreateVideo(params CreateVideoParams) (Video, error) {
 		updated_at,
 		title,
 		description,
+		visibility,
 		user_id
-	) VALUES (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?, ?)
+	) VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := c.db.Exec(query, id, params.Title, params.Description, params.UserID)
+	_, err := c.db.Exec(query, id, now, now, params.Title, params.Description, params.Visibility, params.UserID)
 	if err != nil {
 		return Video{}, err
 	}
@@ -86,34 +162,27 @@ func (c Client) CreateVideo(params CreateVideoParams) (Video, error) {
 	return c.GetVideo(id)
 }
 
-func (c Client) GetVideo(id uuid.UUID) (Video, error) {
+// GetVideo returns the video with the given ID, or ErrNotFound if there is
+// none. Videos in the trash are treated as missing.
+func (c sqlStore) GetVideo(id uuid.UUID) (Video, error) {
+	return c.getVideo(id, false)
+}
+
+func (c sqlStore) getVideo(id uuid.UUID, trashed bool) (Video, error) {
+	condition := "deleted_at IS NULL"
+	if trashed {
+		condition = "deleted_at IS NOT NULL"
+	}
 	query := `
-	SELECT
-		id,
-		created_at,
-		updated_at,
-		title,
-		description,
-		thumbnail_url,
-		video_url,
-		user_id
+	SELECT` + videoColumns + `
 	FROM videos
-	WHERE id = ?
+	WHERE id = ? AND ` + condition + `
 	`
 
-	var video Video
-	err := c.db.QueryRow(query, id).Scan(
-		&video.ID,
-		&video.CreatedAt,
-		&video.UpdatedAt,
-		&video.Title,
-		&video.Description,
-		&video.ThumbnailURL,
-		&video.VideoURL,
-		&video.UserID)
+	video, err := scanVideo(c.db.QueryRow(query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return Video{}, nil
+			return Video{}, ErrNotFound
 		}
 		return Video{}, err
 	}
@@ -121,31 +190,71 @@ func (c Client) GetVideo(id uuid.UUID) (Video, error) {
 	return video, nil
 }
 
-func (c Client) UpdateVideo(video Video) error {
+// UpdateVideo saves the video's editable fields, bumps updated_at and
+// returns the stored row.
+func (c sqlStore) UpdateVideo(video Video) (Video, error) {
+	return c.updateVideo(video, false)
+}
+
+// UpdateVideoIfUnmodified is UpdateVideo, but only succeeds if the stored
+// updated_at still equals video.UpdatedAt. Otherwise it returns ErrConflict.
+func (c sqlStore) UpdateVideoIfUnmodified(video Video) (Video, error) {
+	return c.updateVideo(video, true)
+}
+
+func (c sqlStore) updateVideo(video Video, checkUnmodified bool) (Video, error) {
 	query := `
 	UPDATE videos
 	SET
+		updated_at = ?,
 		title = ?,
 		description = ?,
 		thumbnail_url = ?,
 		video_url = ?,
+		orientation = ?,
+		video_size = ?,
+		thumbnail_size = ?,
+		visibility = ?,
 		user_id = ?
 	WHERE id = ?
 	`
-
-	_, err := c.db.Exec(
-		query,
+	args := []any{
+		timestamp(),
 		video.Title,
 		video.Description,
 		&video.ThumbnailURL,
 		&video.VideoURL,
+		&video.Orientation,
+		video.VideoSize,
+		video.ThumbnailSize,
+		video.Visibility,
 		video.UserID,
 		video.ID,
-	)
-	return err
+	}
+	if checkUnmodified {
+		query += "AND updated_at = ?"
+		args = append(args, video.UpdatedAt)
+	}
+
+	result, err := c.db.Exec(query, args...)
+	if err != nil {
+		return Video{}, err
+	}
+	if checkUnmodified {
+		n, err := result.RowsAffected()
+		if err != nil {
+			return Video{}, err
+		}
+		if n == 0 {
+			return Video{}, ErrConflict
+		}
+	}
+	return c.GetVideo(video.ID)
 }
 
-func (c Client) DeleteVideo(id uuid.UUID) error {
+// DeleteVideo removes the video permanently. Use TrashVideo for deletes that
+// can be undone.
+func (c sqlStore) DeleteVideo(id uuid.UUID) error {
 	query := `
 	DELETE FROM videos
 	WHERE id = ?
@@ -153,3 +262,9 @@ func (c Client) DeleteVideo(id uuid.UUID) error {
 	_, err := c.db.Exec(query, id)
 	return err
 }
+
+// timestamp returns the current time as it will round-trip through every
+// supported driver, so values read back compare equal to what was written.
+func timestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
@@ -0,0 +1,54 @@
+package media
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// Fake is a Processor for tests that never runs external binaries.
+// ProcessForFastStart copies the input unchanged.
+type Fake struct {
+	Probed       ProbeResult
+	ProbeErr     error
+	FastStartErr error
+}
+
+func (f Fake) Probe(ctx context.Context, filePath string) (ProbeResult, error) {
+	if err := ctx.Err(); err != nil {
+		return ProbeResult{}, err
+	}
+	if f.ProbeErr != nil {
+		return ProbeResult{}, f.ProbeErr
+	}
+	return f.Probed, nil
+}
+
+func (f Fake) ProcessForFastStart(ctx context.Context, filePath string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if f.FastStartErr != nil {
+		return "", f.FastStartErr
+	}
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	outputFilePath := filePath + ".processing"
+	dst, err := os.Create(outputFilePath)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		os.Remove(outputFilePath)
+		return "", err
+	}
+	return outputFilePath, nil
+}
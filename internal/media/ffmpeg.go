@@ -0,0 +1,126 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFFmpegPath  = "ffmpeg"
+	defaultFFprobePath = "ffprobe"
+)
+
+// FFmpeg is a Processor that shells out to the ffmpeg and ffprobe binaries.
+type FFmpeg struct {
+	FFmpegPath  string
+	FFprobePath string
+	// Timeout bounds every command. Zero means no limit beyond the caller's context.
+	Timeout time.Duration
+}
+
+func NewFFmpeg(ffmpegPath, ffprobePath string, timeout time.Duration) FFmpeg {
+	if ffmpegPath == "" {
+		ffmpegPath = defaultFFmpegPath
+	}
+	if ffprobePath == "" {
+		ffprobePath = defaultFFprobePath
+	}
+	return FFmpeg{
+		FFmpegPath:  ffmpegPath,
+		FFprobePath: ffprobePath,
+		Timeout:     timeout,
+	}
+}
+
+type probeOutput struct {
+	Streams []struct {
+		CodecType string  `json:"codec_type"`
+		Width     float64 `json:"width"`
+		Height    float64 `json:"height"`
+		Duration  string  `json:"duration"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+func (f FFmpeg) Probe(ctx context.Context, filePath string) (ProbeResult, error) {
+	stdout, err := f.run(ctx, f.FFprobePath, "-v", "error", "-print_format", "json", "-show_streams", "-show_format", filePath)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+
+	var probe probeOutput
+	err = json.Unmarshal(stdout, &probe)
+	if err != nil {
+		return ProbeResult{}, fmt.Errorf("unable to unmarshal ffprobe output: %w", err)
+	}
+
+	for _, stream := range probe.Streams {
+		if stream.CodecType != "video" {
+			continue
+		}
+		// Containers don't always record a per-stream duration, so fall
+		// back to the container's.
+		durationString := stream.Duration
+		if durationString == "" {
+			durationString = probe.Format.Duration
+		}
+		seconds, err := strconv.ParseFloat(durationString, 64)
+		if err != nil {
+			return ProbeResult{}, fmt.Errorf("unable to parse duration %q: %w", durationString, err)
+		}
+		return ProbeResult{
+			Width:    int(stream.Width),
+			Height:   int(stream.Height),
+			Duration: time.Duration(seconds * float64(time.Second)),
+		}, nil
+	}
+	return ProbeResult{}, errors.New("no video stream available")
+}
+
+func (f FFmpeg) ProcessForFastStart(ctx context.Context, filePath string) (string, error) {
+	outputFilePath := filePath + ".processing"
+	_, err := f.run(ctx, f.FFmpegPath, "-i", filePath, "-c", "copy", "-movflags", "faststart", "-f", "mp4", outputFilePath)
+	if err != nil {
+		os.Remove(outputFilePath)
+		return "", err
+	}
+	return outputFilePath, nil
+}
+
+// run executes the binary and returns its stdout. On failure the returned
+// error includes whatever the command wrote to stderr.
+func (f FFmpeg) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return nil, fmt.Errorf("%s failed: %w", name, err)
+		}
+		return nil, fmt.Errorf("%s failed: %w: %s", name, err, msg)
+	}
+	return stdout.Bytes(), nil
+}
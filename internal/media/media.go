@@ -0,0 +1,55 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	AspectRatioLandscape = "16:9"
+	AspectRatioPortrait  = "9:16"
+	AspectRatioOther     = "other"
+)
+
+// ProbeResult describes the first video stream of a file.
+type ProbeResult struct {
+	Width    int
+	Height   int
+	Duration time.Duration
+}
+
+// AspectRatio returns "16:9", "9:16" or "other" for the probed stream.
+func (p ProbeResult) AspectRatio() (string, error) {
+	if p.Width == 0 || p.Height == 0 {
+		return "", errors.New("resolution cannot be 0")
+	}
+	return aspectRatio(float64(p.Width), float64(p.Height)), nil
+}
+
+// Processor inspects and prepares uploaded video files.
+type Processor interface {
+	// Probe reads the dimensions and duration of the file's first video stream.
+	Probe(ctx context.Context, filePath string) (ProbeResult, error)
+	// ProcessForFastStart writes a copy of the file with the moov atom at the
+	// front and returns the path of the new file. The caller owns that file.
+	ProcessForFastStart(ctx context.Context, filePath string) (string, error)
+}
+
+func aspectRatio(width, height float64) string {
+	ratio := floatToThreeDecimals(width / height)
+
+	switch ratio {
+	case floatToThreeDecimals(16.0 / 9.0):
+		return AspectRatioLandscape
+	case floatToThreeDecimals(9.0 / 16.0):
+		return AspectRatioPortrait
+	default:
+		return AspectRatioOther
+	}
+}
+
+func floatToThreeDecimals(float float64) float64 {
+	return math.Floor(float*1000) / 1000
+}
@@ -5,18 +5,21 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/media"
 
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
 	db               database.Client
-	jwtSecret        string
+	jwtKeys          *auth.KeySet
 	platform         string
 	filepathRoot     string
 	assetsRoot       string
@@ -24,25 +27,50 @@ type apiConfig struct {
 	s3Region         string
 	s3CfDistribution string
 	port             string
-	s3Client         *s3.Client
+	s3Client         s3API
+	media            media.Processor
+	trashRetention   time.Duration
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
+	quota            storageQuota
+	plans            map[string]uploadPlan
 }
 
 func main() {
 	godotenv.Load(".env")
 
-	pathToDB := os.Getenv("DB_PATH")
-	if pathToDB == "" {
-		log.Fatal("DB_URL must be set")
+	dbDriver := os.Getenv("DB_DRIVER")
+	if dbDriver == "" {
+		dbDriver = database.DriverSQLite
 	}
 
-	db, err := database.NewClient(pathToDB)
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		dbURL = os.Getenv("DB_PATH")
+	}
+	if dbURL == "" {
+		log.Fatal("DB_URL or DB_PATH must be set")
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(dbDriver, dbURL, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		runAdmin(dbDriver, dbURL, os.Args[2:])
+		return
+	}
+
+	db, err := database.Open(dbDriver, dbURL)
 	if err != nil {
 		log.Fatalf("Couldn't connect to database: %v", err)
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is not set")
+	// JWT_SECRET is optional once JWT_KEYS_FILE is set, but keeping it lets
+	// tokens signed with it stay valid until they expire.
+	jwtKeys, err := auth.LoadKeySet(os.Getenv("JWT_KEYS_FILE"), os.Getenv("JWT_SECRET"))
+	if err != nil {
+		log.Fatalf("Couldn't load JWT signing keys (set JWT_SECRET or JWT_KEYS_FILE): %v", err)
 	}
 
 	platform := os.Getenv("PLATFORM")
@@ -80,6 +108,67 @@ func main() {
 		log.Fatal("PORT environment variable is not set")
 	}
 
+	var mediaTimeout time.Duration
+	if timeout := os.Getenv("MEDIA_TIMEOUT"); timeout != "" {
+		mediaTimeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			log.Fatalf("MEDIA_TIMEOUT is not a valid duration: %v", err)
+		}
+	}
+	mediaProcessor := media.NewFFmpeg(os.Getenv("FFMPEG_PATH"), os.Getenv("FFPROBE_PATH"), mediaTimeout)
+
+	accessTokenTTL := 15 * time.Minute
+	if ttl := os.Getenv("ACCESS_TOKEN_TTL"); ttl != "" {
+		accessTokenTTL, err = time.ParseDuration(ttl)
+		if err != nil || accessTokenTTL <= 0 {
+			log.Fatalf("ACCESS_TOKEN_TTL is not a valid duration: %v", err)
+		}
+	}
+	refreshTokenTTL := 60 * 24 * time.Hour
+	if ttl := os.Getenv("REFRESH_TOKEN_TTL"); ttl != "" {
+		refreshTokenTTL, err = time.ParseDuration(ttl)
+		if err != nil || refreshTokenTTL <= 0 {
+			log.Fatalf("REFRESH_TOKEN_TTL is not a valid duration: %v", err)
+		}
+	}
+	if accessTokenTTL >= refreshTokenTTL {
+		log.Fatal("ACCESS_TOKEN_TTL must be shorter than REFRESH_TOKEN_TTL")
+	}
+
+	trashRetention := 30 * 24 * time.Hour
+	if retention := os.Getenv("TRASH_RETENTION"); retention != "" {
+		trashRetention, err = time.ParseDuration(retention)
+		if err != nil {
+			log.Fatalf("TRASH_RETENTION is not a valid duration: %v", err)
+		}
+	}
+	trashPurgeInterval := time.Hour
+	if interval := os.Getenv("TRASH_PURGE_INTERVAL"); interval != "" {
+		trashPurgeInterval, err = time.ParseDuration(interval)
+		if err != nil || trashPurgeInterval <= 0 {
+			log.Fatalf("TRASH_PURGE_INTERVAL is not a valid duration: %v", err)
+		}
+	}
+
+	var quota storageQuota
+	if maxBytes := os.Getenv("QUOTA_MAX_BYTES"); maxBytes != "" {
+		quota.maxBytes, err = strconv.ParseInt(maxBytes, 10, 64)
+		if err != nil || quota.maxBytes < 0 {
+			log.Fatalf("QUOTA_MAX_BYTES must be a non-negative number of bytes: %v", err)
+		}
+	}
+	if maxVideos := os.Getenv("QUOTA_MAX_VIDEOS"); maxVideos != "" {
+		quota.maxVideos, err = strconv.Atoi(maxVideos)
+		if err != nil || quota.maxVideos < 0 {
+			log.Fatalf("QUOTA_MAX_VIDEOS must be a non-negative number: %v", err)
+		}
+	}
+
+	plans, err := loadUploadPlans(os.Getenv("PLANS_FILE"))
+	if err != nil {
+		log.Fatalf("Couldn't load upload plans: %v", err)
+	}
+
 	awsCfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(s3Region))
 	if err != nil {
 		log.Fatalf("Couldn't load AWS config: %v", err)
@@ -88,7 +177,7 @@ func main() {
 
 	cfg := apiConfig{
 		db:               db,
-		jwtSecret:        jwtSecret,
+		jwtKeys:          jwtKeys,
 		platform:         platform,
 		filepathRoot:     filepathRoot,
 		assetsRoot:       assetsRoot,
@@ -97,6 +186,12 @@ func main() {
 		s3CfDistribution: s3CfDistribution,
 		port:             port,
 		s3Client:         s3Client,
+		media:            mediaProcessor,
+		trashRetention:   trashRetention,
+		accessTokenTTL:   accessTokenTTL,
+		refreshTokenTTL:  refreshTokenTTL,
+		quota:            quota,
+		plans:            plans,
 	}
 
 	err = cfg.ensureAssetsDir()
@@ -104,6 +199,9 @@ func main() {
 		log.Fatalf("Couldn't create assets directory: %v", err)
 	}
 
+	go runPeriodically(context.Background(), trashPurgeInterval, cfg.purgeTrash)
+	go runPeriodically(context.Background(), time.Hour, cfg.purgeDeniedAccessTokens)
+
 	mux := http.NewServeMux()
 	appHandler := http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))
 	mux.Handle("/app/", appHandler)
@@ -111,20 +209,57 @@ func main() {
 	assetsHandler := http.StripPrefix("/assets", http.FileServer(http.Dir(assetsRoot)))
 	mux.Handle("/assets/", noCacheMiddleware(assetsHandler))
 
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.handlerJWKS)
+
+	// Routes without requireAuth or optionalAuth are public, or authenticate
+	// with a refresh token instead of an access token or API key.
 	mux.HandleFunc("POST /api/login", cfg.handlerLogin)
 	mux.HandleFunc("POST /api/refresh", cfg.handlerRefresh)
 	mux.HandleFunc("POST /api/revoke", cfg.handlerRevoke)
 
 	mux.HandleFunc("POST /api/users", cfg.handlerUsersCreate)
+	mux.HandleFunc("PATCH /api/users/me", cfg.requireAuth(auth.ScopeAccountWrite, cfg.handlerUsersUpdate))
+	mux.HandleFunc("DELETE /api/users/me", cfg.requireAuth(auth.ScopeAccountWrite, cfg.handlerUsersDelete))
+	mux.HandleFunc("POST /api/users/me/avatar", cfg.requireAuth(auth.ScopeAccountWrite, cfg.handlerUploadAvatar))
+	mux.HandleFunc("GET /api/users/me/usage", cfg.requireAuth(auth.ScopeVideosRead, cfg.handlerUsageGet))
+	mux.HandleFunc("GET /api/users/{userID}", cfg.handlerUserGet)
+	mux.HandleFunc("GET /api/users/{userID}/videos", cfg.handlerUserVideosRetrieve)
+
+	mux.HandleFunc("GET /api/sessions", cfg.requireAuth(auth.ScopeAccountWrite, cfg.handlerSessionsRetrieve))
+	mux.HandleFunc("DELETE /api/sessions", cfg.requireAuth(auth.ScopeAccountWrite, cfg.handlerSessionsDelete))
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.requireAuth(auth.ScopeAccountWrite, cfg.handlerSessionDelete))
+	mux.HandleFunc("POST /api/access_tokens/revoke", cfg.requireAuth(auth.ScopeAccountWrite, cfg.handlerAccessTokenRevoke))
+	mux.HandleFunc("POST /api/api_keys", cfg.requireAuth(auth.ScopeAccountWrite, cfg.handlerAPIKeysCreate))
+	mux.HandleFunc("GET /api/api_keys", cfg.requireAuth(auth.ScopeAccountWrite, cfg.handlerAPIKeysRetrieve))
+	mux.HandleFunc("DELETE /api/api_keys/{keyID}", cfg.requireAuth(auth.ScopeAccountWrite, cfg.handlerAPIKeyDelete))
+
+	mux.HandleFunc("POST /api/videos", cfg.requireAuth(auth.ScopeVideosWrite, cfg.handlerVideoMetaCreate))
+	mux.HandleFunc("POST /api/thumbnail_upload/{videoID}", cfg.requireAuth(auth.ScopeUploadsWrite, cfg.handlerUploadThumbnail))
+	mux.HandleFunc("POST /api/video_upload/{videoID}", cfg.requireAuth(auth.ScopeUploadsWrite, cfg.handlerUploadVideo))
+	mux.HandleFunc("GET /api/videos", cfg.requireAuth(auth.ScopeVideosRead, cfg.handlerVideosRetrieve))
+	mux.HandleFunc("GET /api/videos/search", cfg.requireAuth(auth.ScopeVideosRead, cfg.handlerVideosSearch))
+	mux.HandleFunc("GET /api/videos/{videoID}", cfg.optionalAuth(auth.ScopeVideosRead, cfg.handlerVideoGet))
+	mux.HandleFunc("PATCH /api/videos/{videoID}", cfg.requireAuth(auth.ScopeVideosWrite, cfg.handlerVideoMetaUpdate))
+	mux.HandleFunc("DELETE /api/videos/{videoID}", cfg.requireAuth(auth.ScopeVideosDelete, cfg.handlerVideoMetaDelete))
+	mux.HandleFunc("GET /api/videos/trash", cfg.requireAuth(auth.ScopeVideosRead, cfg.handlerTrashRetrieve))
+	mux.HandleFunc("POST /api/videos/{videoID}/restore", cfg.requireAuth(auth.ScopeVideosWrite, cfg.handlerVideoRestore))
+	mux.HandleFunc("GET /api/videos/{videoID}/tags", cfg.optionalAuth(auth.ScopeVideosRead, cfg.handlerVideoTagsRetrieve))
+	mux.HandleFunc("PUT /api/videos/{videoID}/tags", cfg.requireAuth(auth.ScopeVideosWrite, cfg.handlerVideoTagsSet))
+	mux.HandleFunc("DELETE /api/videos/{videoID}/tags/{tag}", cfg.requireAuth(auth.ScopeVideosWrite, cfg.handlerVideoTagDelete))
+	mux.HandleFunc("GET /api/tags", cfg.requireAuth(auth.ScopeVideosRead, cfg.handlerTagsRetrieve))
+
+	mux.HandleFunc("POST /api/playlists", cfg.requireAuth(auth.ScopeVideosWrite, cfg.handlerPlaylistsCreate))
+	mux.HandleFunc("GET /api/playlists", cfg.requireAuth(auth.ScopeVideosRead, cfg.handlerPlaylistsRetrieve))
+	mux.HandleFunc("GET /api/playlists/{playlistID}", cfg.optionalAuth(auth.ScopeVideosRead, cfg.handlerPlaylistGet))
+	mux.HandleFunc("PATCH /api/playlists/{playlistID}", cfg.requireAuth(auth.ScopeVideosWrite, cfg.handlerPlaylistUpdate))
+	mux.HandleFunc("DELETE /api/playlists/{playlistID}", cfg.requireAuth(auth.ScopeVideosWrite, cfg.handlerPlaylistDelete))
+	mux.HandleFunc("POST /api/playlists/{playlistID}/entries", cfg.requireAuth(auth.ScopeVideosWrite, cfg.handlerPlaylistEntryAdd))
+	mux.HandleFunc("PATCH /api/playlists/{playlistID}/entries/{videoID}", cfg.requireAuth(auth.ScopeVideosWrite, cfg.handlerPlaylistEntryMove))
+	mux.HandleFunc("DELETE /api/playlists/{playlistID}/entries/{videoID}", cfg.requireAuth(auth.ScopeVideosWrite, cfg.handlerPlaylistEntryDelete))
 
-	mux.HandleFunc("POST /api/videos", cfg.handlerVideoMetaCreate)
-	mux.HandleFunc("POST /api/thumbnail_upload/{videoID}", cfg.handlerUploadThumbnail)
-	mux.HandleFunc("POST /api/video_upload/{videoID}", cfg.handlerUploadVideo)
-	mux.HandleFunc("GET /api/videos", cfg.handlerVideosRetrieve)
-	mux.HandleFunc("GET /api/videos/{videoID}", cfg.handlerVideoGet)
-	mux.HandleFunc("DELETE /api/videos/{videoID}", cfg.handlerVideoMetaDelete)
+	mux.HandleFunc("GET /api/public/videos", cfg.handlerPublicVideosRetrieve)
 
-	mux.HandleFunc("POST /admin/reset", cfg.handlerReset)
+	mux.HandleFunc("POST /admin/reset", cfg.requireAuth(auth.ScopeAdmin, cfg.handlerReset))
 
 	srv := &http.Server{
 		Addr:    ":" + port,
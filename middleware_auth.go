@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+const authContextKey contextKey = "auth"
+
+var (
+	errInvalidJWT    = errors.New("invalid JWT")
+	errInvalidAPIKey = errors.New("invalid API key")
+)
+
+// authInfo describes who made the request. It's stored in the request
+// context by requireAuth and optionalAuth.
+type authInfo struct {
+	UserID uuid.UUID
+	// APIKeyID is set when the caller authenticated with an API key rather
+	// than an access token.
+	APIKeyID uuid.UUID
+	Scopes   []string
+	// AccessToken is the access token the caller authenticated with, if
+	// they didn't use an API key.
+	AccessToken auth.AccessToken
+}
+
+// requireAuth rejects requests without a valid access token or API key, or
+// whose credentials don't grant scope, and passes the caller's identity on
+// to next through the request context.
+func (cfg *apiConfig) requireAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := cfg.authenticate(r)
+		if err != nil {
+			respondWithAuthError(w, err)
+			return
+		}
+		if !checkScope(w, info, scope) {
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), authContextKey, info)))
+	}
+}
+
+// optionalAuth lets anonymous requests through, but still rejects
+// credentials that are present and invalid, or that don't grant scope,
+// rather than silently ignoring them.
+func (cfg *apiConfig) optionalAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := cfg.authenticate(r)
+		if errors.Is(err, auth.ErrNoAuthHeaderIncluded) {
+			next(w, r)
+			return
+		}
+		if err != nil {
+			respondWithAuthError(w, err)
+			return
+		}
+		if !checkScope(w, info, scope) {
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), authContextKey, info)))
+	}
+}
+
+// checkScope writes a 403 and reports false if the caller's credentials
+// don't grant scope.
+func checkScope(w http.ResponseWriter, info authInfo, scope string) bool {
+	if !auth.HasScope(info.Scopes, scope) {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("Missing scope %s", scope), nil)
+		return false
+	}
+	return true
+}
+
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNoAuthHeaderIncluded):
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+	case errors.Is(err, errInvalidJWT):
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+	case errors.Is(err, errInvalidAPIKey):
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate API key", err)
+	default:
+		respondWithError(w, http.StatusInternalServerError, "Couldn't authenticate request", err)
+	}
+}
+
+// authenticate accepts either "Authorization: Bearer <access token>" or
+// "Authorization: ApiKey <key>".
+func (cfg *apiConfig) authenticate(r *http.Request) (authInfo, error) {
+	if strings.HasPrefix(r.Header.Get("Authorization"), "ApiKey ") {
+		return cfg.authenticateAPIKey(r)
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if errors.Is(err, auth.ErrNoAuthHeaderIncluded) {
+		return authInfo{}, err
+	}
+	if err != nil {
+		return authInfo{}, fmt.Errorf("%w: %v", errInvalidJWT, err)
+	}
+	accessToken, err := auth.ValidateJWT(token, cfg.jwtKeys)
+	if err != nil {
+		return authInfo{}, fmt.Errorf("%w: %v", errInvalidJWT, err)
+	}
+	denied, err := cfg.db.IsAccessTokenDenied(accessToken.ID)
+	if err != nil {
+		return authInfo{}, err
+	}
+	if denied {
+		return authInfo{}, fmt.Errorf("%w: token has been revoked", errInvalidJWT)
+	}
+	return authInfo{UserID: accessToken.UserID, Scopes: accessToken.Scopes, AccessToken: accessToken}, nil
+}
+
+func (cfg *apiConfig) authenticateAPIKey(r *http.Request) (authInfo, error) {
+	key, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		return authInfo{}, fmt.Errorf("%w: %v", errInvalidAPIKey, err)
+	}
+	apiKey, err := cfg.db.GetAPIKeyByKey(key)
+	if errors.Is(err, database.ErrNotFound) {
+		return authInfo{}, errInvalidAPIKey
+	}
+	if err != nil {
+		return authInfo{}, err
+	}
+
+	// Failing to record the last use shouldn't fail the request.
+	err = cfg.db.TouchAPIKey(apiKey.ID)
+	if err != nil {
+		log.Printf("Couldn't update last use of API key %s: %v", apiKey.ID, err)
+	}
+
+	return authInfo{UserID: apiKey.UserID, APIKeyID: apiKey.ID, Scopes: apiKey.Scopes}, nil
+}
+
+// authUserID returns the authenticated user's ID, or uuid.Nil for anonymous
+// requests on optionalAuth routes.
+func authUserID(r *http.Request) uuid.UUID {
+	info, _ := r.Context().Value(authContextKey).(authInfo)
+	return info.UserID
+}
+
+// authScopes returns the scopes granted to the caller, or nil for anonymous
+// requests.
+func authScopes(r *http.Request) []string {
+	info, _ := r.Context().Value(authContextKey).(authInfo)
+	return info.Scopes
+}
+
+// authAPIKeyID returns the ID of the API key the request was made with, or
+// uuid.Nil if it wasn't made with one.
+func authAPIKeyID(r *http.Request) uuid.UUID {
+	info, _ := r.Context().Value(authContextKey).(authInfo)
+	return info.APIKeyID
+}
+
+// authAccessToken returns the access token the request was made with, or
+// the zero AccessToken if it was made with an API key or anonymously.
+func authAccessToken(r *http.Request) auth.AccessToken {
+	info, _ := r.Context().Value(authContextKey).(authInfo)
+	return info.AccessToken
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/media"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestRequireAuthAccessTokens(t *testing.T) {
+	const secret = "secret"
+
+	tests := []struct {
+		name string
+		edit func(claims *jwt.MapClaims)
+		// deny puts the token's jti on the deny-list first.
+		deny       bool
+		wantStatus int
+	}{
+		{
+			name:       "valid",
+			edit:       func(claims *jwt.MapClaims) {},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wrong audience",
+			edit:       func(claims *jwt.MapClaims) { (*claims)["aud"] = "another-service" },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong issuer",
+			edit:       func(claims *jwt.MapClaims) { (*claims)["iss"] = "someone-else" },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "missing jti",
+			edit:       func(claims *jwt.MapClaims) { delete(*claims, "jti") },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "denied jti",
+			edit:       func(claims *jwt.MapClaims) {},
+			deny:       true,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := newTestConfig(t, media.Fake{})
+			keys, err := auth.NewKeySet(nil, secret)
+			if err != nil {
+				t.Fatalf("Couldn't create key set: %v", err)
+			}
+			cfg.jwtKeys = keys
+
+			userID := uuid.New()
+			jti := uuid.NewString()
+			claims := jwt.MapClaims{
+				"iss":   string(auth.TokenTypeAccess),
+				"aud":   auth.AccessTokenAudience,
+				"sub":   userID.String(),
+				"iat":   time.Now().Unix(),
+				"exp":   time.Now().Add(time.Minute).Unix(),
+				"jti":   jti,
+				"scope": auth.ScopeVideosRead,
+			}
+			tt.edit(&claims)
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+			if err != nil {
+				t.Fatalf("Couldn't sign token: %v", err)
+			}
+			if tt.deny {
+				user, err := cfg.db.CreateUser(database.CreateUserParams{Email: "boots@example.com", Password: "hash"})
+				if err != nil {
+					t.Fatalf("Couldn't create user: %v", err)
+				}
+				err = cfg.db.DenyAccessToken(database.DenyAccessTokenParams{
+					ID:        jti,
+					UserID:    user.ID,
+					ExpiresAt: time.Now().Add(time.Minute),
+				})
+				if err != nil {
+					t.Fatalf("Couldn't deny access token: %v", err)
+				}
+			}
+
+			called := false
+			handler := cfg.requireAuth(auth.ScopeVideosRead, func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				if got := authUserID(r); got != userID {
+					t.Errorf("authUserID = %v, want %v", got, userID)
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/api/videos", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if called != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("handler called = %v", called)
+			}
+		})
+	}
+}
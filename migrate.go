@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+const migrateUsage = "usage: tubely migrate [up | down [steps] | version]"
+
+// runMigrate implements the `migrate` subcommand.
+func runMigrate(dbDriver, dbURL string, args []string) {
+	db, err := database.Connect(dbDriver, dbURL)
+	if err != nil {
+		log.Fatalf("Couldn't connect to database: %v", err)
+	}
+	defer db.Close()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		err = db.MigrateUp()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+		err = db.MigrateDown(steps)
+	case "version":
+	default:
+		log.Fatal(migrateUsage)
+	}
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		log.Fatalf("Couldn't read schema version: %v", err)
+	}
+	fmt.Printf("Schema version: %d\n", version)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/media"
+	"github.com/google/uuid"
+)
+
+// defaultPlanName is the plan given to new users. It must always be defined.
+const defaultPlanName = "free"
+
+// uploadPlan holds the upload limits of one plan tier. MaxDurationSeconds
+// and MaxResolution are unlimited when zero.
+type uploadPlan struct {
+	MaxVideoBytes int64 `json:"max_video_bytes"`
+	// MaxThumbnailBytes limits every image upload, avatars included.
+	MaxThumbnailBytes  int64 `json:"max_thumbnail_bytes"`
+	MaxDurationSeconds int   `json:"max_duration_seconds"`
+	// MaxResolution limits the shorter side of the video in pixels, so 1080
+	// allows both 1920x1080 and 1080x1920.
+	MaxResolution int `json:"max_resolution"`
+}
+
+var defaultUploadPlans = map[string]uploadPlan{
+	"free": {
+		MaxVideoBytes:      1 << 30,
+		MaxThumbnailBytes:  10 << 20,
+		MaxDurationSeconds: 30 * 60,
+		MaxResolution:      1080,
+	},
+	"pro": {
+		MaxVideoBytes:     10 << 30,
+		MaxThumbnailBytes: 20 << 20,
+		MaxResolution:     2160,
+	},
+}
+
+// loadUploadPlans reads plans from a JSON file mapping plan names to limits,
+// or returns the defaults if path is empty.
+func loadUploadPlans(path string) (map[string]uploadPlan, error) {
+	if path == "" {
+		return defaultUploadPlans, nil
+	}
+
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plans := map[string]uploadPlan{}
+	err = json.Unmarshal(dat, &plans)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := plans[defaultPlanName]; !ok {
+		return nil, fmt.Errorf("no %q plan defined", defaultPlanName)
+	}
+	for name, plan := range plans {
+		if plan.MaxVideoBytes <= 0 || plan.MaxThumbnailBytes <= 0 {
+			return nil, fmt.Errorf("plan %q must set max_video_bytes and max_thumbnail_bytes", name)
+		}
+		if plan.MaxDurationSeconds < 0 || plan.MaxResolution < 0 {
+			return nil, fmt.Errorf("plan %q has a negative limit", name)
+		}
+	}
+	return plans, nil
+}
+
+// uploadPlanFor returns the limits that apply to the user. Users on a plan
+// that is no longer configured fall back to the default plan.
+func (cfg *apiConfig) uploadPlanFor(userID uuid.UUID) (uploadPlan, error) {
+	user, err := cfg.db.GetUser(userID)
+	if err != nil {
+		return uploadPlan{}, err
+	}
+
+	plan, ok := cfg.plans[user.Plan]
+	if !ok {
+		log.Printf("User %s has unknown plan %q, using %q", userID, user.Plan, defaultPlanName)
+		plan = cfg.plans[defaultPlanName]
+	}
+	return plan, nil
+}
+
+// checkVideo returns an error describing the first limit the probed video
+// breaks, or nil if it's within the plan.
+func (p uploadPlan) checkVideo(probe media.ProbeResult) error {
+	maxDuration := time.Duration(p.MaxDurationSeconds) * time.Second
+	if maxDuration > 0 && probe.Duration > maxDuration {
+		return fmt.Errorf("video can't be longer than %v", maxDuration)
+	}
+	if p.MaxResolution > 0 && min(probe.Width, probe.Height) > p.MaxResolution {
+		return fmt.Errorf("video resolution can't be higher than %dp", p.MaxResolution)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+var (
+	errStorageQuotaExceeded = errors.New("storage quota exceeded")
+	errVideoQuotaExceeded   = errors.New("video limit reached")
+)
+
+// storageQuota limits what each user may store. Zero means unlimited.
+type storageQuota struct {
+	maxBytes  int64
+	maxVideos int
+}
+
+// checkStorageQuota returns errStorageQuotaExceeded if swapping freed bytes
+// of the user's stored files for added bytes would take them over quota.
+//
+// Nothing is reserved between the check and the upload being recorded, so
+// uploads running at the same time can each pass against the same usage.
+// A user can therefore end up over quota by at most the combined size of
+// their in-flight uploads, each of which is capped by the plan's maximum
+// upload size. Later uploads are refused until usage drops back under.
+func (cfg *apiConfig) checkStorageQuota(userID uuid.UUID, freed, added int64) error {
+	if cfg.quota.maxBytes == 0 {
+		return nil
+	}
+	usage, err := cfg.db.GetStorageUsage(userID)
+	if err != nil {
+		return err
+	}
+	if usage.TotalBytes-freed+added > cfg.quota.maxBytes {
+		return errStorageQuotaExceeded
+	}
+	return nil
+}
+
+// checkVideoQuota returns errVideoQuotaExceeded if the user can't create
+// another video.
+func (cfg *apiConfig) checkVideoQuota(userID uuid.UUID) error {
+	if cfg.quota.maxVideos == 0 {
+		return nil
+	}
+	usage, err := cfg.db.GetStorageUsage(userID)
+	if err != nil {
+		return err
+	}
+	if usage.VideoCount >= cfg.quota.maxVideos {
+		return errVideoQuotaExceeded
+	}
+	return nil
+}
+
+// respondWithQuotaError writes the response for an error returned by
+// checkStorageQuota.
+func respondWithQuotaError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errStorageQuotaExceeded) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Upload would exceed your storage quota", err)
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "Couldn't check storage quota", err)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+// s3API is the part of the S3 client the handlers use, so tests can swap in
+// a fake.
+type s3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// deleteS3Object removes an object left behind by a request that didn't
+// finish. It detaches from ctx's cancellation so the cleanup still runs after
+// the client has gone away.
+func (cfg *apiConfig) deleteS3Object(ctx context.Context, key string) {
+	_, err := cfg.s3Client.DeleteObject(context.WithoutCancel(ctx), &s3.DeleteObjectInput{
+		Bucket: &cfg.s3Bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		log.Printf("Couldn't delete s3 object %s: %v", key, err)
+	}
+}
+
+// videoObjectKey recovers the S3 key from a video URL served through the
+// CloudFront distribution.
+func (cfg *apiConfig) videoObjectKey(videoURL string) (string, bool) {
+	return strings.CutPrefix(videoURL, cfg.s3CfDistribution+"/")
+}
+
+// deleteVideoAssets removes the stored video and thumbnail files for a video.
+// Failures are logged rather than returned so one missing object doesn't
+// block deleting the rest.
+func (cfg *apiConfig) deleteVideoAssets(ctx context.Context, video database.Video) {
+	if video.VideoURL != nil {
+		if key, ok := cfg.videoObjectKey(*video.VideoURL); ok {
+			cfg.deleteS3Object(ctx, key)
+		}
+	}
+	if video.ThumbnailURL != nil {
+		cfg.deleteAsset(*video.ThumbnailURL)
+	}
+}
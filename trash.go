@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// purgeTrash permanently deletes videos that have been in the trash longer
+// than the retention period, along with their stored files.
+func (cfg *apiConfig) purgeTrash(ctx context.Context) {
+	videos, err := cfg.db.GetVideosTrashedBefore(time.Now().Add(-cfg.trashRetention))
+	if err != nil {
+		log.Printf("Couldn't retrieve expired trash: %v", err)
+		return
+	}
+
+	for _, video := range videos {
+		err := cfg.db.DeleteVideo(video.ID)
+		if err != nil {
+			log.Printf("Couldn't purge video %s: %v", video.ID, err)
+			continue
+		}
+		cfg.deleteVideoAssets(ctx, video)
+	}
+	if len(videos) > 0 {
+		log.Printf("Purged %d videos from the trash", len(videos))
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+// canViewVideo reports whether the user may see the video. Anonymous callers
+// pass uuid.Nil. Unlisted and public videos are open to anyone with the ID;
+// private ones only to their owner.
+func canViewVideo(userID uuid.UUID, video database.Video) bool {
+	return video.Visibility != database.VisibilityPrivate || video.UserID == userID
+}
+
+// canModifyVideo reports whether the user may change or delete the video.
+func canModifyVideo(userID uuid.UUID, video database.Video) bool {
+	return userID != uuid.Nil && video.UserID == userID
+}
+
+// getModifiableVideo loads the video named by the videoID path value for a
+// handler that changes it. It responds with 404 if the video doesn't exist
+// and 403 if the caller doesn't own it, and reports false if the handler
+// should stop.
+func (cfg *apiConfig) getModifiableVideo(w http.ResponseWriter, r *http.Request) (database.Video, bool) {
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return database.Video{}, false
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get video", err)
+		return database.Video{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return database.Video{}, false
+	}
+	return video, checkModifiableVideo(w, r, video)
+}
+
+// getViewableVideo loads the video named by the videoID path value for a
+// handler that reads it. Videos the caller can't see look missing rather
+// than forbidden, so it responds with 404 for both.
+func (cfg *apiConfig) getViewableVideo(w http.ResponseWriter, r *http.Request) (database.Video, bool) {
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return database.Video{}, false
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if errors.Is(err, database.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get video", err)
+		return database.Video{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return database.Video{}, false
+	}
+	if !canViewVideo(authUserID(r), video) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get video", nil)
+		return database.Video{}, false
+	}
+	return video, true
+}
+
+// checkModifiableVideo applies getModifiableVideo's ownership check to a
+// video the handler loaded itself.
+func checkModifiableVideo(w http.ResponseWriter, r *http.Request, video database.Video) bool {
+	if !canModifyVideo(authUserID(r), video) {
+		respondWithError(w, http.StatusForbidden, "You can't modify this video", nil)
+		return false
+	}
+	return true
+}